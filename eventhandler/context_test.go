@@ -0,0 +1,138 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"testing"
+	"time"
+)
+
+type funcContextHandler struct {
+	action string
+	fn     func(ctx context.Context, event pubsub.Event) error
+}
+
+func (f *funcContextHandler) Action() string {
+	return pubsub.NormalizeActionPath(f.action)
+}
+
+func (f *funcContextHandler) HandleContext(ctx context.Context, event pubsub.Event) error {
+	return f.fn(ctx, event)
+}
+
+type contextKey struct{}
+
+func TestRegistry_RouteContext(t *testing.T) {
+	reg := NewRegistry()
+
+	var received context.Context
+
+	_ = reg.Register(Contextual(&funcContextHandler{action: "/contextual", fn: func(ctx context.Context, event pubsub.Event) error {
+		received = ctx
+		return ctx.Err()
+	}}))
+
+	deadline := time.Now().Add(time.Minute)
+
+	ctx, cancel := context.WithDeadline(context.WithValue(context.Background(), contextKey{}, "value"), deadline)
+	defer cancel()
+
+	if err := reg.RouteContext(ctx, newIncomingEvent(t, "/contextual")); err != nil {
+		t.Fatalf("failed to route: %v", err)
+	}
+
+	if d, ok := received.Deadline(); !ok || !d.Equal(deadline) || received.Value(contextKey{}) != "value" {
+		t.Errorf("expected the handler to receive the context of RouteContext")
+	}
+
+	cancel()
+
+	if err := reg.RouteContext(ctx, newIncomingEvent(t, "/contextual")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	reg := NewRegistry()
+
+	blocking := func(ctx context.Context, event pubsub.Event) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+
+	_ = reg.Register(Wrap(Contextual(&funcContextHandler{action: "/bounded", fn: blocking}), Timeout(10*time.Millisecond)))
+
+	_ = reg.Register(Contextual(&funcContextHandler{action: "/unbounded", fn: func(ctx context.Context, event pubsub.Event) error {
+		if _, ok := ctx.Deadline(); ok {
+			return errors.New("unexpected deadline")
+		}
+
+		return nil
+	}}))
+
+	start := time.Now()
+
+	if err := reg.Route(newIncomingEvent(t, "/bounded")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the handler to stop at the deadline")
+	}
+
+	if err := reg.Route(newIncomingEvent(t, "/unbounded")); err != nil {
+		t.Errorf("expected the timeout to only bound the wrapped handler, got %v", err)
+	}
+}
+
+func TestDispatcher_ShutdownCancelsHandlers(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+
+	_ = reg.Register(Contextual(&funcContextHandler{action: "/long-write", fn: func(ctx context.Context, event pubsub.Event) error {
+		close(started)
+
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+
+		return ctx.Err()
+	}}))
+
+	_ = ps.Subscribe(pubsub.NewTopic("long-write", ""))
+
+	d := NewDispatcher(ps, reg)
+
+	_ = d.Run()
+
+	publish(t, ps, "long-write", "/long-write", nil)
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the handler to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timeout waiting for the handler to be cancelled")
+	}
+}
@@ -0,0 +1,291 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"hash/fnv"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// DefaultDispatcherQueueSize is the capacity of the queue of every dispatcher worker.
+const DefaultDispatcherQueueSize = 64
+
+var (
+	ErrDispatcherRunning = errors.New("dispatcher already running")
+	ErrDispatcherStopped = errors.New("dispatcher already stopped")
+)
+
+// KeyFunc returns the ordering key of the event: the events sharing a key are handled one by one in their order,
+// while the events of different keys are handled in parallel.
+type KeyFunc func(event pubsub.Event) string
+
+// KeyByTopic orders the events by EventID.Topic, which is the default KeyFunc of Dispatcher.
+func KeyByTopic(event pubsub.Event) string {
+	return event.ID().Topic
+}
+
+// DispatcherOption configures a Dispatcher.
+type DispatcherOption func(*dispatcherOptions)
+
+type dispatcherOptions struct {
+	workers   int
+	queueSize int
+
+	key KeyFunc
+
+	onError     func(event pubsub.Event, err error)
+	onUnmatched func(event pubsub.Event)
+}
+
+// WithWorkers sets the number of the workers handling the events in parallel; the default is GOMAXPROCS.
+func WithWorkers(n int) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets the capacity of the queue of every worker; the default is DefaultDispatcherQueueSize.
+func WithQueueSize(n int) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		if n >= 0 {
+			o.queueSize = n
+		}
+	}
+}
+
+// WithOrderingKey sets how the events are ordered, e.g. by a key derived from the payload; the default is KeyByTopic.
+func WithOrderingKey(key KeyFunc) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		if key != nil {
+			o.key = key
+		}
+	}
+}
+
+// WithErrorHandler sets the callback receiving the errors returned by the handlers.
+func WithErrorHandler(fn func(event pubsub.Event, err error)) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.onError = fn
+	}
+}
+
+// WithUnmatchedHandler sets the callback receiving the events without a handler of their actions.
+func WithUnmatchedHandler(fn func(event pubsub.Event)) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.onUnmatched = fn
+	}
+}
+
+// Dispatcher routes the events of a UnifiedPubSub to the handlers of a Registry with a pool of workers.
+// Every event is queued to the worker chosen by its ordering key, so the events of a key are handled in order.
+// The callbacks are called from the workers, so they must be safe for concurrent use.
+// A panic of a handler is reported to the error handler as *pubsub.PanicError, and the worker goes on.
+//
+// The events are routed via Registry.RouteContext with a context which is cancelled once the PubSub is stopped,
+// i.e. when Shutdown gives up waiting for the handlers, or when the Events() channel is closed.
+//
+// An event is consumed from the PubSub (i.e. its offset is synced into the SyncPoint) once it is taken from Events(),
+// before it is handled, so the events left unhandled by Shutdown are lost when resuming from its SyncPoint;
+// see Shutdown. Within a consumer group (see pubsub.WithConsumerGroup), the events which are not acknowledged
+// by their handlers stay pending instead, and are redelivered by reclaiming (see pubsub.WithPendingReclaim).
+type Dispatcher struct {
+	ps       pubsub.UnifiedPubSub
+	registry Registry
+	options  *dispatcherOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	queues []chan pubsub.Event
+
+	stop chan struct{}
+	wg   *sync.WaitGroup
+
+	mu      *sync.Mutex
+	running bool
+	stopped bool
+}
+
+func NewDispatcher(ps pubsub.UnifiedPubSub, registry Registry, opts ...DispatcherOption) *Dispatcher {
+	o := &dispatcherOptions{
+		workers:   runtime.GOMAXPROCS(0),
+		queueSize: DefaultDispatcherQueueSize,
+		key:       KeyByTopic,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		ps:       ps,
+		registry: registry,
+		options:  o,
+		ctx:      ctx,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Run starts the workers and dispatching the events in the background, until Shutdown is called
+// or the Events() channel of the PubSub is closed.
+func (d *Dispatcher) Run() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+
+	if d.running {
+		return ErrDispatcherRunning
+	}
+
+	d.running = true
+	d.queues = make([]chan pubsub.Event, d.options.workers)
+
+	for i := range d.queues {
+		queue := make(chan pubsub.Event, d.options.queueSize)
+		d.queues[i] = queue
+
+		d.wg.Add(1)
+
+		go func() {
+			defer d.wg.Done()
+
+			for event := range queue {
+				d.handle(event)
+			}
+		}()
+	}
+
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		d.dispatch()
+	}()
+
+	return nil
+}
+
+// dispatch queues the events to the workers until stopped, then closes the queues, so that the workers exit once drained.
+func (d *Dispatcher) dispatch() {
+	defer func() {
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}()
+
+	events := d.ps.Events()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// the PubSub is stopped by someone else, so the queued events are routed with the cancelled context
+				d.cancel()
+				return
+			}
+
+			// the event is already consumed, so it is queued even if stopped meanwhile;
+			// the workers keep draining their queues until they are closed, so this does not block forever
+			d.queues[d.index(d.options.key(event))] <- event
+		}
+	}
+}
+
+// index returns the worker of the ordering key.
+func (d *Dispatcher) index(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(d.options.workers))
+}
+
+func (d *Dispatcher) handle(event pubsub.Event) {
+	err := d.route(event)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrHandlerNotFound):
+		if d.options.onUnmatched != nil {
+			d.options.onUnmatched(event)
+		}
+	default:
+		if d.options.onError != nil {
+			d.options.onError(event, err)
+		}
+	}
+}
+
+// route routes the event, turning a panic of the handlers into a *pubsub.PanicError carrying the stack,
+// so that a panicking handler does not kill the worker.
+func (d *Dispatcher) route(event pubsub.Event) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &pubsub.PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	return d.registry.RouteContext(d.ctx, event)
+}
+
+// Shutdown stops dispatching, waits for the workers to handle the queued events until ctx is done,
+// then cancels the context of the handlers, stops the PubSub and returns its SyncPoint.
+// Every event taken from the PubSub before stopping is queued, even if it waits for a full queue,
+// while the ones left in the queues when ctx is done are not handled, and ctx.Err() is returned
+// without waiting for the handlers to return.
+// Those events are already consumed from the PubSub, i.e. their offsets are synced into the returned SyncPoint,
+// so they are lost: resuming from the SyncPoint does not deliver them again.
+// To lose none of them, wait with a ctx without deadline, or consume within a consumer group, see Dispatcher.
+func (d *Dispatcher) Shutdown(ctx context.Context) (pubsub.SyncPoint, error) {
+	d.mu.Lock()
+
+	if d.stopped {
+		d.mu.Unlock()
+		return pubsub.SyncPoint{}, ErrDispatcherStopped
+	}
+
+	d.stopped = true
+	close(d.stop)
+
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	var waitErr error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	d.cancel()
+
+	point, err := d.ps.Stop()
+
+	if waitErr != nil {
+		return point, waitErr
+	}
+
+	return point, err
+}
@@ -0,0 +1,412 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"sync"
+	"testing"
+	"time"
+)
+
+type funcHandler struct {
+	action string
+	fn     func(event pubsub.Event) error
+}
+
+func (f *funcHandler) Action() string {
+	return pubsub.NormalizeActionPath(f.action)
+}
+
+func (f *funcHandler) Handle(event pubsub.Event) error {
+	return f.fn(event)
+}
+
+func publish(t *testing.T, ps pubsub.UnifiedPubSub, topic, action string, payload interface{}) {
+	event, err := pubsub.NewOutgoingEvent(&pubsub.EventID{Topic: topic}, action, 0, payload)
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	if err := ps.Publish(context.Background(), event); err != nil {
+		t.Fatalf("failed to publish event: %v", err)
+	}
+}
+
+// newMemoryStream creates an in-memory stream PubSub with the streams created, as Publish does not create them.
+func newMemoryStream(streams ...string) pubsub.UnifiedPubSub {
+	return pubsub.WithMemoryStream(0, pubsub.WithMemoryStreams(streams...))
+}
+
+func TestDispatcher_Ordering(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	mu := &sync.Mutex{}
+	handled := make(map[string][]int)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(90)
+
+	_ = reg.Register(&funcHandler{action: "/ordered", fn: func(event pubsub.Event) error {
+		defer wg.Done()
+
+		var seq int
+
+		if err := event.UnmarshalPayload(&seq); err != nil {
+			return err
+		}
+
+		// the later events of a key would overtake the earlier ones if they were handled in parallel
+		time.Sleep(time.Duration(10-seq%10) * time.Millisecond / 10)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled[event.ID().Topic] = append(handled[event.ID().Topic], seq)
+
+		return nil
+	}})
+
+	topics := []string{"key-a", "key-b", "key-c"}
+
+	for _, topic := range topics {
+		_ = ps.Subscribe(pubsub.NewTopic(topic, ""))
+	}
+
+	d := NewDispatcher(ps, reg, WithWorkers(4))
+
+	if err := d.Run(); err != nil {
+		t.Fatalf("failed to run dispatcher: %v", err)
+	}
+
+	if err := d.Run(); !errors.Is(err, ErrDispatcherRunning) {
+		t.Errorf("expected ErrDispatcherRunning, got %v", err)
+	}
+
+	for seq := 0; seq < 30; seq++ {
+		for _, topic := range topics {
+			publish(t, ps, topic, "/ordered", seq)
+		}
+	}
+
+	wg.Wait()
+
+	_, err := d.Shutdown(context.Background())
+
+	if err != nil {
+		t.Errorf("failed to shut down dispatcher: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, topic := range topics {
+		if len(handled[topic]) != 30 {
+			t.Fatalf("expected 30 events of %s, got %d", topic, len(handled[topic]))
+		}
+
+		for i, seq := range handled[topic] {
+			if seq != i {
+				t.Fatalf("expected the events of %s in order, got %v", topic, handled[topic])
+			}
+		}
+	}
+}
+
+func TestDispatcher_ParallelKeys(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	released := make(chan struct{})
+
+	// the blocked key must not hold up the other key
+	_ = reg.Register(&funcHandler{action: "/blocked", fn: func(event pubsub.Event) error {
+		select {
+		case <-released:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("not released")
+		}
+	}})
+
+	_ = reg.Register(&funcHandler{action: "/release", fn: func(event pubsub.Event) error {
+		close(released)
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("blocked", ""), pubsub.NewTopic("release", ""))
+
+	errs := make(chan error, 1)
+
+	d := NewDispatcher(ps, reg, WithWorkers(2), WithErrorHandler(func(event pubsub.Event, err error) {
+		errs <- err
+	}))
+
+	if d.index("blocked") == d.index("release") {
+		t.Fatalf("expected the keys to be queued to different workers")
+	}
+
+	_ = d.Run()
+
+	publish(t, ps, "blocked", "/blocked", nil)
+	publish(t, ps, "release", "/release", nil)
+
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the release")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+
+	select {
+	case err := <-errs:
+		t.Errorf("expected the keys to be handled in parallel, got %v", err)
+	default:
+	}
+}
+
+func TestDispatcher_Callbacks(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	handlerErr := errors.New("handler failed")
+
+	_ = reg.Register(&funcHandler{action: "/failing", fn: func(event pubsub.Event) error {
+		return handlerErr
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("callbacks", ""))
+
+	errs := make(chan error, 1)
+	unmatched := make(chan string, 1)
+
+	d := NewDispatcher(ps, reg, WithErrorHandler(func(event pubsub.Event, err error) {
+		errs <- err
+	}), WithUnmatchedHandler(func(event pubsub.Event) {
+		unmatched <- event.Action()
+	}))
+
+	_ = d.Run()
+
+	publish(t, ps, "callbacks", "/failing", nil)
+	publish(t, ps, "callbacks", "/unknown", nil)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, handlerErr) {
+			t.Errorf("expected the handler error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the handler error")
+	}
+
+	select {
+	case action := <-unmatched:
+		if action != "/unknown" {
+			t.Errorf("expected /unknown to be unmatched, got %s", action)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the unmatched event")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+}
+
+func TestDispatcher_Shutdown(t *testing.T) {
+	ps := newMemoryStream("slow")
+	reg := NewRegistry()
+
+	started := make(chan struct{}, 3)
+
+	mu := &sync.Mutex{}
+	finished := 0
+
+	_ = reg.Register(&funcHandler{action: "/slow", fn: func(event pubsub.Event) error {
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		finished++
+
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("slow", ""))
+
+	d := NewDispatcher(ps, reg, WithWorkers(1))
+
+	_ = d.Run()
+
+	for i := 0; i < 3; i++ {
+		publish(t, ps, "slow", "/slow", fmt.Sprintf("%d", i))
+	}
+
+	<-started
+
+	point, err := d.Shutdown(context.Background())
+
+	if err != nil {
+		t.Errorf("failed to shut down dispatcher: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the in-flight handler finishes, along with the queued events
+	if finished != len(started)+1 {
+		t.Errorf("expected every started handler to finish, got %d of %d", finished, len(started)+1)
+	}
+
+	if len(point.Offsets) != 1 {
+		t.Errorf("expected the SyncPoint of the stopped pubsub, got %v", point)
+	}
+
+	if _, ok := <-ps.Events(); ok {
+		t.Errorf("expected the pubsub to be stopped")
+	}
+
+	if _, err := d.Shutdown(context.Background()); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("expected ErrDispatcherStopped, got %v", err)
+	}
+}
+
+func TestDispatcher_ShutdownTimeout(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	_ = reg.Register(&funcHandler{action: "/stuck", fn: func(event pubsub.Event) error {
+		close(started)
+		<-release
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("stuck", ""))
+
+	d := NewDispatcher(ps, reg)
+
+	_ = d.Run()
+
+	publish(t, ps, "stuck", "/stuck", nil)
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := d.Shutdown(ctx)
+
+	close(release)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDispatcher_ShutdownFullQueue(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	_ = reg.Register(&funcHandler{action: "/stuck", fn: func(event pubsub.Event) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("stuck", ""))
+
+	d := NewDispatcher(ps, reg, WithWorkers(1), WithQueueSize(0))
+
+	_ = d.Run()
+
+	publish(t, ps, "stuck", "/stuck", nil)
+
+	<-started
+
+	// the second event waits for the queue of the stuck worker
+	publish(t, ps, "stuck", "/stuck", nil)
+	time.Sleep(20 * time.Millisecond)
+
+	shutdown := make(chan error, 1)
+
+	go func() {
+		_, err := d.Shutdown(context.Background())
+		shutdown <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("failed to shut down dispatcher: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the shutdown")
+	}
+
+	// the event waiting for the queue is already consumed, so it is handled before stopping
+	if len(started) != 1 {
+		t.Errorf("expected the waiting event to be handled")
+	}
+}
+
+func TestDispatcher_Panic(t *testing.T) {
+	ps := pubsub.WithMemory()
+	reg := NewRegistry()
+
+	_ = reg.Register(&funcHandler{action: "/panic", fn: func(event pubsub.Event) error {
+		panic("boom")
+	}})
+
+	handled := make(chan struct{})
+
+	_ = reg.Register(&funcHandler{action: "/ok", fn: func(event pubsub.Event) error {
+		close(handled)
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("panic", ""))
+
+	errs := make(chan error, 1)
+
+	d := NewDispatcher(ps, reg, WithWorkers(1), WithErrorHandler(func(event pubsub.Event, err error) {
+		errs <- err
+	}))
+
+	_ = d.Run()
+
+	publish(t, ps, "panic", "/panic", nil)
+	publish(t, ps, "panic", "/ok", nil)
+
+	select {
+	case err := <-errs:
+		var panicErr *pubsub.PanicError
+
+		if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Errorf("expected PanicError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the panic")
+	}
+
+	// the worker goes on after the panic
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the next event")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+}
@@ -0,0 +1,131 @@
+package eventhandler
+
+import (
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistry_FanOut(t *testing.T) {
+	reg := NewRegistry()
+
+	handled := make([]string, 0)
+	analyticsErr := errors.New("analytics failed")
+
+	_ = reg.RegisterAs("notification", &funcHandler{action: "/message/created", fn: func(event pubsub.Event) error {
+		handled = append(handled, "notification")
+		return nil
+	}})
+
+	_ = reg.RegisterAs("analytics", &funcHandler{action: "/message/created", fn: func(event pubsub.Event) error {
+		handled = append(handled, "analytics")
+		return analyticsErr
+	}})
+
+	if err := reg.RegisterAs("analytics", &funcHandler{action: "/message/created"}); !errors.Is(err, ErrHandlerRegistered) {
+		t.Errorf("expected ErrHandlerRegistered, got %v", err)
+	}
+
+	err := reg.Route(newIncomingEvent(t, "/message/created"))
+
+	if !errors.Is(err, analyticsErr) || !strings.Contains(err.Error(), "analytics") {
+		t.Errorf("expected the error of the analytics handler, got %v", err)
+	}
+
+	if len(handled) != 2 || handled[0] != "notification" || handled[1] != "analytics" {
+		t.Errorf("expected the handlers to be invoked in order, got %v", handled)
+	}
+
+	if err := reg.Unregister("/message/created", "unknown"); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+
+	if err := reg.Unregister("/message/created", "analytics"); err != nil {
+		t.Errorf("failed to unregister: %v", err)
+	}
+
+	handled = handled[:0]
+
+	if err := reg.Route(newIncomingEvent(t, "/message/created")); err != nil {
+		t.Errorf("failed to route: %v", err)
+	}
+
+	if len(handled) != 1 || handled[0] != "notification" {
+		t.Errorf("expected only the notification handler to be invoked, got %v", handled)
+	}
+
+	if err := reg.Unregister("/message/created", "notification"); err != nil {
+		t.Errorf("failed to unregister: %v", err)
+	}
+
+	if reg.Has("/message/created") {
+		t.Errorf("expected the pattern to have no handler")
+	}
+}
+
+func TestRegistry_ConcurrentFanOut(t *testing.T) {
+	reg := NewRegistry(WithConcurrentFanOut())
+
+	// every handler waits for the others, so they would block forever if invoked one by one
+	barrier := &sync.WaitGroup{}
+	barrier.Add(3)
+
+	failures := []error{errors.New("first failed"), nil, errors.New("third failed")}
+
+	for i, id := range []string{"first", "second", "third"} {
+		failure := failures[i]
+
+		_ = reg.RegisterAs(id, &funcHandler{action: "/fan-out", fn: func(event pubsub.Event) error {
+			barrier.Done()
+			barrier.Wait()
+
+			return failure
+		}})
+	}
+
+	routed := make(chan error, 1)
+
+	go func() {
+		routed <- reg.Route(newIncomingEvent(t, "/fan-out"))
+	}()
+
+	select {
+	case err := <-routed:
+		if !errors.Is(err, failures[0]) || !errors.Is(err, failures[2]) {
+			t.Errorf("expected the errors to be joined, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the handlers to be invoked concurrently")
+	}
+}
+
+func TestRegistry_FanOutParamNames(t *testing.T) {
+	reg := NewRegistry()
+
+	received := make(map[string]Params)
+
+	// the patterns differ only in the names of their params, so they are the same pattern
+	for id, pattern := range map[string]string{"by-room": "/room/:roomId/message", "by-id": "/room/:id/message"} {
+		id := id
+
+		_ = reg.RegisterAs(id, &funcHandler{action: pattern, fn: func(event pubsub.Event) error {
+			received[id] = ParamsOf(event)
+			return nil
+		}})
+	}
+
+	if err := reg.Route(newIncomingEvent(t, "/room/42/message")); err != nil {
+		t.Fatalf("failed to route: %v", err)
+	}
+
+	if p := received["by-room"]; len(p) != 1 || p["roomId"] != "42" {
+		t.Errorf("expected the params under roomId, got %v", p)
+	}
+
+	if p := received["by-id"]; len(p) != 1 || p["id"] != "42" {
+		t.Errorf("expected the params under id, got %v", p)
+	}
+}
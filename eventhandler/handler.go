@@ -1,6 +1,9 @@
 package eventhandler
 
-import "github.com/edgejumps/sportstalk-pubsub/pubsub"
+import (
+	"context"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+)
 
 // EventHandler is an interface that defines the behavior of an event handler.
 // An event handler is responsible for handling an event matching a specific Action.
@@ -13,3 +16,38 @@ type EventHandler interface {
 type EventDataBuilder interface {
 	Build(payload interface{}, ttl int) (pubsub.EventData, error)
 }
+
+// ContextHandler is an EventHandler receiving the context of the event explicitly, which is done when
+// the routing is cancelled (e.g. Dispatcher.Shutdown, see Registry.RouteContext) or times out (see Timeout).
+// It is registered via Contextual.
+type ContextHandler interface {
+	Action() string
+	HandleContext(ctx context.Context, event pubsub.Event) error
+}
+
+// contextHandler adapts a ContextHandler to EventHandler.
+type contextHandler struct {
+	ContextHandler
+}
+
+func (h *contextHandler) Handle(event pubsub.Event) error {
+	return h.HandleContext(event.Context(), event)
+}
+
+// Build keeps the EventDataBuilder of the adapted handler available to Registry.BuildData.
+func (h *contextHandler) Build(payload interface{}, ttl int) (pubsub.EventData, error) {
+	b, ok := h.ContextHandler.(EventDataBuilder)
+
+	if !ok {
+		return nil, ErrNoAvailableEventDataBuilder
+	}
+
+	return b.Build(payload, ttl)
+}
+
+// Contextual adapts the handler to EventHandler, whose Handle passes pubsub.Event.Context() to HandleContext.
+func Contextual(handler ContextHandler) EventHandler {
+	return &contextHandler{
+		ContextHandler: handler,
+	}
+}
@@ -0,0 +1,27 @@
+package eventhandler
+
+import (
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"time"
+)
+
+// Metrics receives the measurements of Registry.Route.
+// It is called from the routing goroutines, so it must be safe for concurrent use and must not block.
+type Metrics interface {
+	// EventHandled records an event routed by the registry, with how long the routing took and its error,
+	// e.g. ErrHandlerNotFound for an unmatched action.
+	EventHandled(topic, action string, duration time.Duration, err error)
+}
+
+// WithMetrics makes the registry record every routed event into m.
+func WithMetrics(m Metrics) Option {
+	return func(r *registry) {
+		r.metrics = m
+	}
+}
+
+func (r *registry) recordHandled(event pubsub.Event, duration time.Duration, err error) {
+	if r.metrics != nil {
+		r.metrics.EventHandled(event.ID().Topic, event.Action(), duration, err)
+	}
+}
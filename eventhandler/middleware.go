@@ -0,0 +1,131 @@
+package eventhandler
+
+import (
+	"context"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"runtime/debug"
+	"time"
+)
+
+// HandlerFunc handles an event, as EventHandler.Handle does.
+type HandlerFunc func(event pubsub.Event) error
+
+// Middleware wraps the handling of the events with cross-cutting logic, e.g. logging, recovery or dedupe.
+// It calls next to continue handling, or returns without calling it to stop there.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chain wraps fn with the middleware, the first of which is the outermost.
+func chain(fn HandlerFunc, middleware []Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fn = middleware[i](fn)
+	}
+
+	return fn
+}
+
+// WithMiddleware makes the registry wrap every handler with the middleware, the first of which is the outermost.
+// The middleware of the registry wraps the middleware of a handler given to Wrap.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(r *registry) {
+		r.middleware = append(r.middleware, middleware...)
+	}
+}
+
+// wrappedHandler is a handler wrapped with its own middleware.
+type wrappedHandler struct {
+	EventHandler
+
+	handle HandlerFunc
+}
+
+func (h *wrappedHandler) Handle(event pubsub.Event) error {
+	return h.handle(event)
+}
+
+// Build keeps the EventDataBuilder of the wrapped handler available to Registry.BuildData.
+func (h *wrappedHandler) Build(payload interface{}, ttl int) (pubsub.EventData, error) {
+	b, ok := h.EventHandler.(EventDataBuilder)
+
+	if !ok {
+		return nil, ErrNoAvailableEventDataBuilder
+	}
+
+	return b.Build(payload, ttl)
+}
+
+// Wrap returns the handler wrapped with the middleware, the first of which is the outermost.
+func Wrap(handler EventHandler, middleware ...Middleware) EventHandler {
+	return &wrappedHandler{
+		EventHandler: handler,
+		handle:       chain(handler.Handle, middleware),
+	}
+}
+
+// Recover turns a panic of the handler into a *pubsub.PanicError carrying the stack,
+// so that a panicking handler does not kill the goroutine routing the events.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &pubsub.PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+
+			return next(event)
+		}
+	}
+}
+
+// Timing reports how long every event takes to be handled, along with the error of the handling.
+func Timing(observe func(event pubsub.Event, duration time.Duration, err error)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) error {
+			start := time.Now()
+
+			err := next(event)
+
+			observe(event, time.Since(start), err)
+
+			return err
+		}
+	}
+}
+
+// SkipExpired skips the events whose TTL has elapsed since their timestamps, counting the TTL in the given unit
+// (e.g. time.Second). The events with TTL <= pubsub.MinimumTTL, or without a timestamp, never expire.
+func SkipExpired(unit time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) error {
+			if expired(event, unit, time.Now()) {
+				return nil
+			}
+
+			return next(event)
+		}
+	}
+}
+
+func expired(event pubsub.Event, unit time.Duration, now time.Time) bool {
+	if event.TTL() <= pubsub.MinimumTTL || event.Timestamp() <= 0 {
+		return false
+	}
+
+	deadline := time.UnixMilli(event.Timestamp()).Add(time.Duration(event.TTL()) * unit)
+
+	return now.After(deadline)
+}
+
+// Timeout bounds the handling of every event with the timeout, via the deadline of pubsub.Event.Context().
+// Only the handlers respecting the context (e.g. ContextHandler) would stop at the deadline.
+// Use it via Wrap for the timeout of a handler, or via WithMiddleware for the timeout of every handler.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) error {
+			ctx, cancel := context.WithTimeout(event.Context(), timeout)
+			defer cancel()
+
+			return next(withContext(event, ctx))
+		}
+	}
+}
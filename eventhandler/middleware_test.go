@@ -0,0 +1,153 @@
+package eventhandler
+
+import (
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"testing"
+	"time"
+)
+
+func tracing(name string, calls *[]string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) error {
+			*calls = append(*calls, name+":before")
+			err := next(event)
+			*calls = append(*calls, name+":after")
+
+			return err
+		}
+	}
+}
+
+func TestMiddleware_Order(t *testing.T) {
+	calls := make([]string, 0)
+
+	reg := NewRegistry(WithMiddleware(tracing("outer", &calls), tracing("inner", &calls)))
+
+	handler := Wrap(&funcHandler{action: "/ordered", fn: func(event pubsub.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	}}, tracing("handler", &calls))
+
+	_ = reg.Register(handler)
+
+	if err := reg.Route(newIncomingEvent(t, "/ordered")); err != nil {
+		t.Fatalf("failed to route: %v", err)
+	}
+
+	expected := []string{
+		"outer:before", "inner:before", "handler:before", "handler",
+		"handler:after", "inner:after", "outer:after",
+	}
+
+	if len(calls) != len(expected) {
+		t.Fatalf("expected the calls %v, got %v", expected, calls)
+	}
+
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("expected the calls %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestMiddleware_Recover(t *testing.T) {
+	reg := NewRegistry(WithMiddleware(Recover()))
+
+	_ = reg.Register(&funcHandler{action: "/panicking", fn: func(event pubsub.Event) error {
+		panic("boom")
+	}})
+
+	err := reg.Route(newIncomingEvent(t, "/panicking"))
+
+	var panicErr *pubsub.PanicError
+
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("expected the panic to be recovered as PanicError, got %v", err)
+	}
+}
+
+func TestMiddleware_Timing(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+
+	var observed time.Duration
+	var observedErr error
+
+	reg := NewRegistry(WithMiddleware(Timing(func(event pubsub.Event, duration time.Duration, err error) {
+		observed = duration
+		observedErr = err
+	})))
+
+	_ = reg.Register(&funcHandler{action: "/slow", fn: func(event pubsub.Event) error {
+		time.Sleep(10 * time.Millisecond)
+		return handlerErr
+	}})
+
+	if err := reg.Route(newIncomingEvent(t, "/slow")); !errors.Is(err, handlerErr) {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+
+	if observed < 10*time.Millisecond || !errors.Is(observedErr, handlerErr) {
+		t.Errorf("expected the duration and the error to be observed, got %v and %v", observed, observedErr)
+	}
+}
+
+func TestMiddleware_SkipExpired(t *testing.T) {
+	handled := 0
+
+	reg := NewRegistry(WithMiddleware(SkipExpired(time.Second)))
+
+	_ = reg.Register(&funcHandler{action: "/expiring", fn: func(event pubsub.Event) error {
+		handled++
+		return nil
+	}})
+
+	now := time.Now()
+
+	cases := []struct {
+		ttl       int
+		timestamp time.Time
+		handled   bool
+	}{
+		{0, now.Add(-time.Hour), true},
+		{60, now.Add(-time.Minute / 2), true},
+		{60, now.Add(-2 * time.Minute), false},
+	}
+
+	for _, c := range cases {
+		handled = 0
+
+		event, err := pubsub.NewIncomingEvent(&pubsub.EventID{Topic: "expiring"}, map[string]interface{}{
+			"action":    "/expiring",
+			"ttl":       c.ttl,
+			"timestamp": c.timestamp.UnixMilli(),
+		})
+
+		if err != nil {
+			t.Fatalf("failed to create event: %v", err)
+		}
+
+		if err := reg.Route(event); err != nil {
+			t.Errorf("failed to route: %v", err)
+		}
+
+		if (handled == 1) != c.handled {
+			t.Errorf("expected the event with TTL %d created at %v to be handled: %v", c.ttl, c.timestamp, c.handled)
+		}
+	}
+}
+
+func TestWrap_KeepsBuilder(t *testing.T) {
+	reg := NewRegistry()
+
+	_ = reg.Register(Wrap(&mockHandler{action: "/built"}, Recover()))
+	_ = reg.Register(Wrap(&funcHandler{action: "/unbuilt"}, Recover()))
+
+	if _, err := reg.BuildData("/built", nil, 1); err != nil {
+		t.Errorf("failed to build data: %v", err)
+	}
+
+	if _, err := reg.BuildData("/unbuilt", nil, 1); !errors.Is(err, ErrNoAvailableEventDataBuilder) {
+		t.Errorf("expected ErrNoAvailableEventDataBuilder, got %v", err)
+	}
+}
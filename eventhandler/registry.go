@@ -1,9 +1,12 @@
 package eventhandler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/edgejumps/sportstalk-pubsub/pubsub"
-	cmap "github.com/orcaman/concurrent-map/v2"
+	"sync"
+	"time"
 )
 
 var (
@@ -13,72 +16,216 @@ var (
 	ErrUnmatchedAction             = errors.New("unmatched action")
 )
 
+// Registry routes the events to the handlers of their actions.
+// The action of a handler can be a pattern, e.g. /room/:roomId/message, /user/* or /user/**,
+// whose captured params are available to the handler via ParamsOf; see ParamPrefix, Wildcard and CatchAll.
+// Has and Unregister take the pattern the handler is registered with.
+//
+// Several handlers can react to the same pattern via RegisterAs, each with its own identifier (fan-out).
+// Route invokes all of them in the order of their registration, or concurrently with WithConcurrentFanOut.
 type Registry interface {
 	Register(handler EventHandler) error
-	Unregister(action string) error
+	RegisterAs(id string, handler EventHandler) error
+	Unregister(action string, ids ...string) error
 	Route(event pubsub.Event) error
+	RouteContext(ctx context.Context, event pubsub.Event) error
 	Has(action string) bool
 
 	BuildData(action string, payload interface{}, ttl int) (pubsub.EventData, error)
 }
 
 type registry struct {
-	handlers cmap.ConcurrentMap[string, EventHandler]
+	router *router
+	mu     *sync.RWMutex
+
+	middleware []Middleware
+
+	metrics Metrics
+
+	concurrent bool
 }
 
-func NewRegistry() Registry {
-	return &registry{
-		handlers: cmap.New[EventHandler](),
+// Option configures a Registry.
+type Option func(*registry)
+
+// WithConcurrentFanOut makes Route invoke the handlers of an action concurrently, waiting for all of them,
+// instead of one by one in the order of their registration.
+func WithConcurrentFanOut() Option {
+	return func(r *registry) {
+		r.concurrent = true
 	}
 }
 
-func (r *registry) Register(handler EventHandler) error {
-	if r.Has(handler.Action()) {
-		return ErrHandlerRegistered
+func NewRegistry(opts ...Option) Registry {
+	r := &registry{
+		router: newRouter(),
+		mu:     &sync.RWMutex{},
 	}
 
-	r.handlers.Set(handler.Action(), handler)
+	for _, opt := range opts {
+		opt(r)
+	}
 
-	return nil
+	return r
 }
 
-func (r *registry) Unregister(action string) error {
-	if !r.Has(action) {
-		return ErrHandlerNotFound
-	}
+// Register registers the handler with its action (pattern) under the empty identifier, see RegisterAs.
+func (r *registry) Register(handler EventHandler) error {
+	return r.RegisterAs("", handler)
+}
 
-	r.handlers.Remove(action)
+// RegisterAs registers the handler with its action (pattern) under the identifier, beside the other handlers of the pattern.
+// It returns ErrInvalidPattern if the pattern is malformed,
+// or ErrHandlerRegistered if another handler is registered with the same pattern and identifier.
+func (r *registry) RegisterAs(id string, handler EventHandler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	return nil
+	return r.router.add(handler.Action(), id, handler)
 }
 
+// Unregister removes the handlers of the pattern registered with the identifiers, or all of them if no identifier is given.
+// It returns ErrHandlerNotFound, removing nothing, if any of them is not registered.
+func (r *registry) Unregister(action string, ids ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.router.remove(action, ids...)
+}
+
+// match returns the handlers of the action, and the values captured from it, see router.match.
+func (r *registry) match(action string) ([]namedHandler, []string, []string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched, values, rest := r.router.match(action)
+
+	if matched == nil {
+		return nil, nil, nil, false
+	}
+
+	return matched.handlers, values, rest, true
+}
+
+// Route routes the event as RouteContext does, with context.Background().
 func (r *registry) Route(event pubsub.Event) error {
+	return r.RouteContext(context.Background(), event)
+}
+
+// RouteContext routes the event to the handlers of its action within a consumer span of ctx,
+// which is a child of the trace context carried by the event (see pubsub.Event.Context()).
+// Each handler receives the event carrying the context of the span, i.e. the cancellation and the deadline of ctx,
+// and the params captured from the action under the names of its pattern, through the middleware of the registry.
+// The errors of the handlers are joined via errors.Join, each prefixed with the identifier of its handler if any.
+// The event carrying HeaderRetryHandler is routed only to the handler with that identifier.
+func (r *registry) RouteContext(ctx context.Context, event pubsub.Event) (err error) {
+
+	ctx, span := startConsumerSpan(ctx, event)
+	start := time.Now()
 
-	h, ok := r.handlers.Get(event.Action())
+	defer func() {
+		endSpan(span, err)
+		r.recordHandled(event, time.Since(start), err)
+	}()
+
+	handlers, values, rest, ok := r.match(event.Action())
 
 	if !ok {
 		return ErrHandlerNotFound
 	}
 
-	return h.Handle(event)
+	// the event re-enqueued by Retry is redelivered only to the handler which failed
+	if id, ok := event.Headers()[HeaderRetryHandler]; ok {
+		handlers = only(handlers, id)
+
+		if len(handlers) == 0 {
+			return ErrHandlerNotFound
+		}
+	}
+
+	handle := func(h namedHandler) error {
+		return r.handle(h, newRoutedEvent(ctx, event, h.id, h.params(values, rest)))
+	}
+
+	if len(handlers) == 1 {
+		return handle(handlers[0])
+	}
+
+	errs := make([]error, len(handlers))
+
+	if !r.concurrent {
+		for i, h := range handlers {
+			errs[i] = handle(h)
+		}
+
+		return errors.Join(errs...)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(handlers))
+
+	for i, h := range handlers {
+		go func(i int, h namedHandler) {
+			defer wg.Done()
+
+			errs[i] = handle(h)
+		}(i, h)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// only returns the handler with the identifier among the handlers, if any.
+func only(handlers []namedHandler, id string) []namedHandler {
+	for _, h := range handlers {
+		if h.id == id {
+			return []namedHandler{h}
+		}
+	}
+
+	return nil
+}
+
+// handle invokes the handler through the middleware, and prefixes its error with the identifier of the handler if any.
+func (r *registry) handle(h namedHandler, event pubsub.Event) error {
+	err := chain(h.handler.Handle, r.middleware)(event)
+
+	if err != nil && h.id != "" {
+		return fmt.Errorf("handler %s: %w", h.id, err)
+	}
+
+	return err
 }
 
 func (r *registry) Has(action string) bool {
-	return r.handlers.Has(action)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.router.has(action)
 }
 
+// BuildData builds the event data via the first EventDataBuilder among the handlers matching the action.
 func (r *registry) BuildData(action string, payload interface{}, ttl int) (pubsub.EventData, error) {
-	h, ok := r.handlers.Get(action)
+	handlers, _, _, ok := r.match(action)
 
 	if !ok {
 		return nil, ErrHandlerNotFound
 	}
 
-	b, ok := h.(EventDataBuilder)
+	for _, h := range handlers {
+		b, ok := h.handler.(EventDataBuilder)
 
-	if !ok {
-		return nil, ErrNoAvailableEventDataBuilder
+		if !ok {
+			continue
+		}
+
+		// a wrapped handler is a builder even if the handler it wraps is not
+		if data, err := b.Build(payload, ttl); !errors.Is(err, ErrNoAvailableEventDataBuilder) {
+			return data, err
+		}
 	}
 
-	return b.Build(payload, ttl)
+	return nil, ErrNoAvailableEventDataBuilder
 }
@@ -0,0 +1,32 @@
+package eventhandler
+
+import (
+	"context"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+)
+
+// Reply publishes the reply with the payload to the request handled by the handler via ps,
+// which is returned by pubsub.Requester.Request to the requester. It returns pubsub.ErrNotRequest
+// if the event is not a request (see pubsub.IsRequest).
+// Pass the context of the handler as ctx, so that the reply is traced within the consumer span.
+func Reply(ctx context.Context, ps pubsub.UnifiedPubSub, request pubsub.Event, payload interface{}) error {
+	reply, err := pubsub.NewReplyEvent(request, payload)
+
+	if err != nil {
+		return err
+	}
+
+	return ps.Publish(ctx, reply)
+}
+
+// ReplyError publishes the reply carrying the error to the request handled by the handler via ps,
+// which is returned as *pubsub.RemoteError by pubsub.Requester.Request, see Reply.
+func ReplyError(ctx context.Context, ps pubsub.UnifiedPubSub, request pubsub.Event, err error) error {
+	reply, replyErr := pubsub.NewErrorReplyEvent(request, err)
+
+	if replyErr != nil {
+		return replyErr
+	}
+
+	return ps.Publish(ctx, reply)
+}
@@ -0,0 +1,75 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"testing"
+)
+
+func TestReply(t *testing.T) {
+	broker := pubsub.NewMemoryBroker()
+
+	server := pubsub.WithMemory(pubsub.WithMemoryBroker(broker))
+	reg := NewRegistry()
+
+	_ = reg.Register(Typed("/message/check", func(ctx context.Context, event pubsub.Event, text string) error {
+		if text == "spam" {
+			return ReplyError(ctx, server, event, errors.New("spam is not allowed"))
+		}
+
+		return Reply(ctx, server, event, map[string]interface{}{"allowed": true})
+	}))
+
+	_ = server.Subscribe(pubsub.NewTopic("moderation", ""))
+
+	d := NewDispatcher(server, reg)
+
+	_ = d.Run()
+
+	defer func() {
+		_, _ = d.Shutdown(context.Background())
+	}()
+
+	requester, err := pubsub.NewRequester(pubsub.WithMemory(pubsub.WithMemoryBroker(broker)), "moderation:replies")
+
+	if err != nil {
+		t.Fatalf("failed to create requester: %v", err)
+	}
+
+	defer func() {
+		_ = requester.Stop()
+	}()
+
+	request := func(text string) (pubsub.Event, error) {
+		event, err := pubsub.NewTypedOutgoingEvent(&pubsub.EventID{Topic: "moderation"}, "/message/check", 0, text)
+
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+
+		return requester.Request(context.Background(), event)
+	}
+
+	reply, err := request("hello")
+
+	if err != nil {
+		t.Fatalf("failed to request: %v", err)
+	}
+
+	var result map[string]interface{}
+
+	if err := reply.UnmarshalPayload(&result); err != nil || result["allowed"] != true {
+		t.Errorf("expected the message to be allowed, got %v (%v)", result, err)
+	}
+
+	var remoteErr *pubsub.RemoteError
+
+	if _, err := request("spam"); !errors.As(err, &remoteErr) {
+		t.Errorf("expected RemoteError, got %v", err)
+	}
+
+	if err := Reply(context.Background(), server, newIncomingEvent(t, "/message/check"), nil); !errors.Is(err, pubsub.ErrNotRequest) {
+		t.Errorf("expected ErrNotRequest, got %v", err)
+	}
+}
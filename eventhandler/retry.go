@@ -0,0 +1,243 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMinBackoff  = 100 * time.Millisecond
+	DefaultRetryMaxBackoff  = 10 * time.Second
+	DefaultRetrySuffix      = ":retry"
+
+	// HeaderRetryAttempt is the attempt of the event re-enqueued to the retry stream, starting from 2.
+	HeaderRetryAttempt = "retry-attempt"
+
+	// HeaderRetryNotBefore is when the event re-enqueued to the retry stream can be handled again,
+	// in unix milliseconds since epoch.
+	HeaderRetryNotBefore = "retry-not-before"
+
+	// HeaderRetryTopic is the topic where the event re-enqueued to the retry stream came from.
+	HeaderRetryTopic = "retry-topic"
+
+	// HeaderRetryHandler is the identifier of the handler whose failure re-enqueued the event to the retry stream,
+	// so that Registry.Route redelivers the event only to that handler instead of all the handlers of the action.
+	HeaderRetryHandler = "retry-handler"
+)
+
+var (
+	// ErrRetryable marks the error of a handler as retryable, e.g. fmt.Errorf("%w: %w", ErrRetryable, err).
+	ErrRetryable = errors.New("retryable")
+
+	ErrRetriesExhausted = errors.New("retries exhausted")
+)
+
+// RetryableError classifies an error of a handler, as an alternative to ErrRetryable.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// IsRetryable reports whether the error is retryable,
+// i.e. it wraps ErrRetryable, or a RetryableError whose Retryable returns true.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrRetryable) {
+		return true
+	}
+
+	var retryable RetryableError
+
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+type retryOptions struct {
+	maxAttempts int
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	retryable func(err error) bool
+
+	// requeue publishes the events to be retried into the retry streams, see WithRetryStream.
+	requeue pubsub.UnifiedPubSub
+	suffix  string
+}
+
+// RetryOption configures Retry.
+type RetryOption func(*retryOptions)
+
+// WithMaxAttempts sets how many times an event is handled at most, including the first attempt.
+// The default is DefaultRetryMaxAttempts.
+func WithMaxAttempts(attempts int) RetryOption {
+	return func(o *retryOptions) {
+		if attempts > 0 {
+			o.maxAttempts = attempts
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before each retry, which doubles from minBackoff up to maxBackoff,
+// with a random jitter of up to half of the delay.
+// The defaults are DefaultRetryMinBackoff and DefaultRetryMaxBackoff.
+func WithRetryBackoff(minBackoff, maxBackoff time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		if minBackoff > 0 {
+			o.minBackoff = minBackoff
+		}
+
+		if maxBackoff >= o.minBackoff {
+			o.maxBackoff = maxBackoff
+		}
+	}
+}
+
+// WithRetryable sets which errors are retried. The default is IsRetryable.
+func WithRetryable(retryable func(err error) bool) RetryOption {
+	return func(o *retryOptions) {
+		if retryable != nil {
+			o.retryable = retryable
+		}
+	}
+}
+
+// WithRetryStream makes Retry re-enqueue the failed events delivered by a stream (i.e. with an entry ID)
+// into their retry streams via ps, instead of blocking the consumer during the backoff.
+// The retry stream of a topic is RetryTopic(topic, suffix), and it must be subscribed and routed
+// through a registry with the same Retry, which delays the events until HeaderRetryNotBefore.
+// An empty suffix means DefaultRetrySuffix.
+//
+// As ps does not create the streams it publishes to (see pubsub.WithStream), the retry streams must be created
+// beforehand, e.g. via XGROUP CREATE with MKSTREAM, or pubsub.WithMemoryStreams. Otherwise the event
+// cannot be re-enqueued, and the error of the handler is returned joined with the error of publishing.
+func WithRetryStream(ps pubsub.UnifiedPubSub, suffix string) RetryOption {
+	return func(o *retryOptions) {
+		o.requeue = ps
+
+		if suffix != "" {
+			o.suffix = suffix
+		}
+	}
+}
+
+// RetryTopic returns the name of the retry stream of the topic.
+// An empty suffix means DefaultRetrySuffix.
+func RetryTopic(topic, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultRetrySuffix
+	}
+
+	return topic + suffix
+}
+
+// Retry handles the event again when the handler returns a retryable error (see WithRetryable),
+// until the handling succeeds or WithMaxAttempts is reached, waiting for the backoff in between.
+// The attempt is available to the handler via AttemptOf. A non-retryable error is returned as is,
+// while the last error after the attempts are exhausted is wrapped with ErrRetriesExhausted.
+//
+// With WithRetryStream, the event delivered by a stream is re-enqueued to its retry stream instead,
+// and the handling is reported as succeeded, so that the event can be acknowledged.
+func Retry(opts ...RetryOption) Middleware {
+	o := &retryOptions{
+		maxAttempts: DefaultRetryMaxAttempts,
+		minBackoff:  DefaultRetryMinBackoff,
+		maxBackoff:  DefaultRetryMaxBackoff,
+		retryable:   IsRetryable,
+		suffix:      DefaultRetrySuffix,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event pubsub.Event) error {
+			ctx := event.Context()
+
+			// the event re-enqueued to the retry stream waits for its backoff here
+			if notBefore := pubsub.ParseTimestamp(event.Headers()[HeaderRetryNotBefore]); notBefore > 0 {
+				if err := wait(ctx, time.Until(time.UnixMilli(notBefore))); err != nil {
+					return err
+				}
+			}
+
+			for attempt := AttemptOf(event); ; attempt++ {
+				err := next(withAttempt(event, attempt))
+
+				if err == nil || !o.retryable(err) {
+					return err
+				}
+
+				if attempt >= o.maxAttempts {
+					return fmt.Errorf("%w after %d attempts: %w", ErrRetriesExhausted, attempt, err)
+				}
+
+				delay := pubsub.Backoff(attempt, o.minBackoff, o.maxBackoff)
+
+				if o.requeue != nil && event.ID().EntryID != "" {
+					if requeueErr := o.requeueEvent(ctx, event, attempt+1, delay); requeueErr != nil {
+						return errors.Join(err, requeueErr)
+					}
+
+					return nil
+				}
+
+				if err := wait(ctx, delay); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// requeueEvent publishes the event into the retry stream of its original topic,
+// keeping its action, TTL, timestamp, headers and payload, and stamping the handler it is routed to.
+func (o *retryOptions) requeueEvent(ctx context.Context, event pubsub.Event, attempt int, delay time.Duration) error {
+	topic := event.Headers()[HeaderRetryTopic]
+
+	if topic == "" {
+		topic = event.ID().Topic
+	}
+
+	opts := []pubsub.EventDataOption{
+		pubsub.WithHeaders(event.Headers()),
+		pubsub.WithHeader(HeaderRetryTopic, topic),
+		pubsub.WithHeader(HeaderRetryAttempt, strconv.Itoa(attempt)),
+		pubsub.WithHeader(HeaderRetryNotBefore, strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)),
+		pubsub.WithTimestamp(event.Timestamp()),
+	}
+
+	if handler, ok := handlerOf(event); ok {
+		opts = append(opts, pubsub.WithHeader(HeaderRetryHandler, handler))
+	}
+
+	retried, err := pubsub.NewOutgoingEvent(&pubsub.EventID{Topic: RetryTopic(topic, o.suffix)},
+		event.Action(), event.TTL(), event.RawPayload(), opts...)
+
+	if err != nil {
+		return err
+	}
+
+	return o.requeue.Publish(ctx, retried)
+}
+
+// wait waits for the delay, and returns the error of the context if it is done first.
+func wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,297 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"sync"
+	"testing"
+	"time"
+)
+
+type temporaryError struct {
+	temporary bool
+}
+
+func (e *temporaryError) Error() string {
+	return fmt.Sprintf("temporary: %v", e.temporary)
+}
+
+func (e *temporaryError) Retryable() bool {
+	return e.temporary
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{errors.New("failed"), false},
+		{fmt.Errorf("%w: %w", ErrRetryable, errors.New("failed")), true},
+		{fmt.Errorf("wrapped: %w", &temporaryError{temporary: true}), true},
+		{&temporaryError{temporary: false}, false},
+	}
+
+	for _, c := range cases {
+		if IsRetryable(c.err) != c.retryable {
+			t.Errorf("expected %v to be retryable: %v", c.err, c.retryable)
+		}
+	}
+}
+
+func TestRetry_Inline(t *testing.T) {
+	reg := NewRegistry(WithMiddleware(Retry(WithMaxAttempts(3), WithRetryBackoff(time.Millisecond, time.Millisecond))))
+
+	permanentErr := errors.New("permanent")
+
+	attempts := make([]int, 0)
+
+	_ = reg.Register(&funcHandler{action: "/flaky", fn: func(event pubsub.Event) error {
+		attempts = append(attempts, AttemptOf(event))
+
+		if AttemptOf(event) < 3 {
+			return fmt.Errorf("%w: not yet", ErrRetryable)
+		}
+
+		return nil
+	}})
+
+	_ = reg.Register(&funcHandler{action: "/broken", fn: func(event pubsub.Event) error {
+		attempts = append(attempts, AttemptOf(event))
+		return &temporaryError{temporary: true}
+	}})
+
+	_ = reg.Register(&funcHandler{action: "/permanent", fn: func(event pubsub.Event) error {
+		attempts = append(attempts, AttemptOf(event))
+		return permanentErr
+	}})
+
+	cases := []struct {
+		action   string
+		attempts int
+		check    func(err error) bool
+	}{
+		{"/flaky", 3, func(err error) bool { return err == nil }},
+		{"/broken", 3, func(err error) bool {
+			var temporary *temporaryError
+			return errors.Is(err, ErrRetriesExhausted) && errors.As(err, &temporary)
+		}},
+		{"/permanent", 1, func(err error) bool { return err == permanentErr }},
+	}
+
+	for _, c := range cases {
+		attempts = attempts[:0]
+
+		err := reg.Route(newIncomingEvent(t, c.action))
+
+		if !c.check(err) {
+			t.Errorf("unexpected error of %s: %v", c.action, err)
+		}
+
+		if len(attempts) != c.attempts {
+			t.Errorf("expected %d attempts of %s, got %v", c.attempts, c.action, attempts)
+		}
+
+		for i, attempt := range attempts {
+			if attempt != i+1 {
+				t.Errorf("expected the attempts of %s to be counted from 1, got %v", c.action, attempts)
+			}
+		}
+	}
+}
+
+func TestRetry_RetryStream(t *testing.T) {
+	ps := newMemoryStream("orders", RetryTopic("orders", ""))
+
+	reg := NewRegistry(WithMiddleware(Retry(
+		WithMaxAttempts(3),
+		WithRetryBackoff(20*time.Millisecond, 20*time.Millisecond),
+		WithRetryStream(ps, ""),
+	)))
+
+	type attempt struct {
+		topic   string
+		attempt int
+		at      time.Time
+	}
+
+	mu := &sync.Mutex{}
+	attempts := make([]attempt, 0)
+	done := make(chan struct{})
+
+	_ = reg.Register(&funcHandler{action: "/flaky", fn: func(event pubsub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts = append(attempts, attempt{event.ID().Topic, AttemptOf(event), time.Now()})
+
+		if AttemptOf(event) < 3 {
+			return fmt.Errorf("%w: not yet", ErrRetryable)
+		}
+
+		close(done)
+
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("orders", ""), pubsub.NewTopic(RetryTopic("orders", ""), ""))
+
+	errs := make(chan error, 3)
+
+	d := NewDispatcher(ps, reg, WithErrorHandler(func(event pubsub.Event, err error) {
+		errs <- err
+	}))
+
+	_ = d.Run()
+
+	publish(t, ps, "orders", "/flaky", "payload")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the retries")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+
+	select {
+	case err := <-errs:
+		t.Errorf("expected the failed attempts to be re-enqueued, got %v", err)
+	default:
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []attempt{
+		{topic: "orders", attempt: 1},
+		{topic: "orders:retry", attempt: 2},
+		{topic: "orders:retry", attempt: 3},
+	}
+
+	if len(attempts) != len(expected) {
+		t.Fatalf("expected the attempts %v, got %v", expected, attempts)
+	}
+
+	for i := range expected {
+		if attempts[i].topic != expected[i].topic || attempts[i].attempt != expected[i].attempt {
+			t.Errorf("expected the attempts %v, got %v", expected, attempts)
+		}
+
+		// the jitter takes up to half of the backoff
+		if i > 0 && attempts[i].at.Sub(attempts[i-1].at) < 10*time.Millisecond {
+			t.Errorf("expected attempt %d to wait for the backoff", attempts[i].attempt)
+		}
+	}
+}
+
+func TestRetry_RetryStreamFanOut(t *testing.T) {
+	ps := newMemoryStream("orders", RetryTopic("orders", ""))
+
+	reg := NewRegistry(WithMiddleware(Retry(
+		WithMaxAttempts(3),
+		WithRetryBackoff(20*time.Millisecond, 20*time.Millisecond),
+		WithRetryStream(ps, ""),
+	)))
+
+	mu := &sync.Mutex{}
+	handled := make([]string, 0)
+	done := make(chan struct{})
+
+	_ = reg.RegisterAs("notification", &funcHandler{action: "/fan-out", fn: func(event pubsub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled = append(handled, fmt.Sprintf("notification@%s", event.ID().Topic))
+
+		return nil
+	}})
+
+	_ = reg.RegisterAs("analytics", &funcHandler{action: "/fan-out", fn: func(event pubsub.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled = append(handled, fmt.Sprintf("analytics@%s", event.ID().Topic))
+
+		if AttemptOf(event) < 2 {
+			return fmt.Errorf("%w: not yet", ErrRetryable)
+		}
+
+		close(done)
+
+		return nil
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("orders", ""), pubsub.NewTopic(RetryTopic("orders", ""), ""))
+
+	d := NewDispatcher(ps, reg)
+
+	_ = d.Run()
+
+	publish(t, ps, "orders", "/fan-out", "payload")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the retry")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the redelivery goes only to the handler which failed
+	expected := []string{"notification@orders", "analytics@orders", "analytics@orders:retry"}
+
+	if len(handled) != len(expected) {
+		t.Fatalf("expected the handling %v, got %v", expected, handled)
+	}
+
+	for i := range expected {
+		if handled[i] != expected[i] {
+			t.Errorf("expected the handling %v, got %v", expected, handled)
+		}
+	}
+}
+
+func TestRetry_MissingRetryStream(t *testing.T) {
+	// the retry stream is not created
+	ps := newMemoryStream("orders")
+
+	reg := NewRegistry(WithMiddleware(Retry(
+		WithMaxAttempts(3),
+		WithRetryBackoff(20*time.Millisecond, 20*time.Millisecond),
+		WithRetryStream(ps, ""),
+	)))
+
+	handlerErr := fmt.Errorf("%w: not yet", ErrRetryable)
+
+	_ = reg.Register(&funcHandler{action: "/flaky", fn: func(event pubsub.Event) error {
+		return handlerErr
+	}})
+
+	_ = ps.Subscribe(pubsub.NewTopic("orders", ""))
+
+	errs := make(chan error, 1)
+
+	d := NewDispatcher(ps, reg, WithErrorHandler(func(event pubsub.Event, err error) {
+		errs <- err
+	}))
+
+	_ = d.Run()
+
+	publish(t, ps, "orders", "/flaky", "payload")
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, handlerErr) || !errors.Is(err, pubsub.ErrStreamNotFound) {
+			t.Errorf("expected the error of the handler joined with ErrStreamNotFound, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the error")
+	}
+
+	_, _ = d.Shutdown(context.Background())
+}
@@ -0,0 +1,139 @@
+package eventhandler
+
+import (
+	"context"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"strconv"
+)
+
+// routedEvent is the event handed to the handler by Registry.Route,
+// carrying the context of the consumer span, the identifier of the handler and the params captured from the action,
+// and the attempt of the handling set by Retry.
+type routedEvent struct {
+	pubsub.Event
+
+	ctx     context.Context
+	handler string
+	params  Params
+	attempt int
+}
+
+func (e *routedEvent) Context() context.Context {
+	return e.ctx
+}
+
+func (e *routedEvent) Handler() string {
+	return e.handler
+}
+
+func (e *routedEvent) Params() Params {
+	return e.params
+}
+
+func (e *routedEvent) Attempt() int {
+	return e.attempt
+}
+
+// routedAckableEvent keeps the routed event acknowledgeable, see pubsub.Ack.
+type routedAckableEvent struct {
+	*routedEvent
+
+	ackable pubsub.AckableEvent
+}
+
+func (e *routedAckableEvent) Ack(ctx context.Context) error {
+	return e.ackable.Ack(ctx)
+}
+
+func (e *routedAckableEvent) DeliveryCount() int64 {
+	return e.ackable.DeliveryCount()
+}
+
+func newRoutedEvent(ctx context.Context, event pubsub.Event, handler string, params Params) pubsub.Event {
+	return keepAckable(&routedEvent{
+		Event:   event,
+		ctx:     ctx,
+		handler: handler,
+		params:  params,
+	})
+}
+
+// derive returns the routed state of the event (its context, params and attempt) wrapping the event,
+// so that the caller can replace a part of it.
+func derive(event pubsub.Event) *routedEvent {
+	routed := &routedEvent{
+		Event:  event,
+		ctx:    event.Context(),
+		params: ParamsOf(event),
+	}
+
+	routed.handler, _ = handlerOf(event)
+
+	if retried, ok := event.(interface{ Attempt() int }); ok {
+		routed.attempt = retried.Attempt()
+	}
+
+	return routed
+}
+
+// withAttempt returns the event carrying the attempt, along with the routed state of the event.
+func withAttempt(event pubsub.Event, attempt int) pubsub.Event {
+	routed := derive(event)
+	routed.attempt = attempt
+
+	return keepAckable(routed)
+}
+
+// withContext returns the event carrying ctx, along with the routed state of the event.
+func withContext(event pubsub.Event, ctx context.Context) pubsub.Event {
+	routed := derive(event)
+	routed.ctx = ctx
+
+	return keepAckable(routed)
+}
+
+func keepAckable(routed *routedEvent) pubsub.Event {
+	if ackable, ok := routed.Event.(pubsub.AckableEvent); ok {
+		return &routedAckableEvent{
+			routedEvent: routed,
+			ackable:     ackable,
+		}
+	}
+
+	return routed
+}
+
+// handlerOf returns the identifier of the handler the event is routed to by Registry.Route,
+// and false if the event is not routed.
+func handlerOf(event pubsub.Event) (string, bool) {
+	if routed, ok := event.(interface{ Handler() string }); ok {
+		return routed.Handler(), true
+	}
+
+	return "", false
+}
+
+// ParamsOf returns the params captured from the action of the event routed by Registry.Route,
+// or nil if the event is not routed.
+func ParamsOf(event pubsub.Event) Params {
+	if routed, ok := event.(interface{ Params() Params }); ok {
+		return routed.Params()
+	}
+
+	return nil
+}
+
+// AttemptOf returns the attempt of handling the event, starting from 1.
+// It is counted by Retry, including the attempts before the event was re-enqueued to the retry stream
+// (see HeaderRetryAttempt); the redeliveries within a consumer group are counted by pubsub.AckableEvent instead.
+func AttemptOf(event pubsub.Event) int {
+	if retried, ok := event.(interface{ Attempt() int }); ok && retried.Attempt() > 0 {
+		return retried.Attempt()
+	}
+
+	if attempt, err := strconv.Atoi(event.Headers()[HeaderRetryAttempt]); err == nil && attempt > 0 {
+		return attempt
+	}
+
+	return 1
+}
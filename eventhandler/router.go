@@ -0,0 +1,320 @@
+package eventhandler
+
+import (
+	"errors"
+	"strings"
+)
+
+// The special segments of an action pattern:
+// ParamPrefix captures one segment by name, e.g. /room/:roomId/message;
+// Wildcard matches any one segment, e.g. /user/*;
+// CatchAll (only as the last segment) matches the rest of the action, including nothing, e.g. /user/** or /**.
+const (
+	ParamPrefix = ":"
+	Wildcard    = "*"
+	CatchAll    = "**"
+)
+
+var ErrInvalidPattern = errors.New("invalid action pattern")
+
+// Params are the segments of the action captured by the pattern of its handler, keyed by their names.
+// The rest matched by CatchAll is keyed by CatchAll, without the leading slash.
+type Params map[string]string
+
+// namedHandler is a handler registered with its identifier, which is empty for Registry.Register.
+type namedHandler struct {
+	id      string
+	handler EventHandler
+
+	// names are the names of the param segments in the pattern of the handler, in their order
+	names []string
+}
+
+// params returns the captured values of the params keyed by the names of the handler,
+// along with the rest matched by the catch-all if any.
+func (h namedHandler) params(values, rest []string) Params {
+	params := make(Params)
+
+	for i, name := range h.names {
+		params[name] = values[i]
+	}
+
+	if rest != nil {
+		params[CatchAll] = strings.Join(rest, "/")
+	}
+
+	return params
+}
+
+// route is the handlers registered with a pattern, in the order of their registration.
+// The handlers are replaced rather than modified in place, so that a matched route can be used without the lock.
+type route struct {
+	pattern  string
+	handlers []namedHandler
+}
+
+// node is a segment of the action patterns.
+type node struct {
+	static   map[string]*node
+	param    *node
+	wildcard *node
+
+	// route is the handlers of the pattern ending at this node, and catchAll the ones of the pattern ending with CatchAll
+	route    *route
+	catchAll *route
+}
+
+func newNode() *node {
+	return &node{
+		static: make(map[string]*node),
+	}
+}
+
+// router matches the actions against the patterns of the handlers segment by segment, in the precedence of
+// static > param > wildcard > catch-all, backtracking to the next kind when the rest of the action does not match.
+// Patterns differing only in the names of their params are the same pattern,
+// while each handler receives the params under the names of the pattern it is registered with.
+type router struct {
+	root *node
+}
+
+func newRouter() *router {
+	return &router{
+		root: newNode(),
+	}
+}
+
+func splitAction(action string) []string {
+	trimmed := strings.Trim(action, "/")
+
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, "/")
+}
+
+// validate checks the segments of the pattern, and returns the names of its params.
+func validate(segments []string) ([]string, error) {
+	names := make([]string, 0)
+
+	for i, segment := range segments {
+		switch {
+		case segment == CatchAll:
+			if i != len(segments)-1 {
+				return nil, ErrInvalidPattern
+			}
+		case strings.HasPrefix(segment, ParamPrefix):
+			if len(segment) == len(ParamPrefix) {
+				return nil, ErrInvalidPattern
+			}
+
+			names = append(names, segment[len(ParamPrefix):])
+		case segment == "":
+			return nil, ErrInvalidPattern
+		}
+	}
+
+	return names, nil
+}
+
+// slot returns the route slot of the pattern, creating the nodes along it if create is true.
+// The pattern must be normalized as the actions, e.g. /action/path.
+func (r *router) slot(pattern string, create bool) (**route, []string, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, nil, ErrInvalidPattern
+	}
+
+	segments := splitAction(pattern)
+
+	names, err := validate(segments)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := r.root
+
+	for _, segment := range segments {
+		if segment == CatchAll {
+			return &current.catchAll, names, nil
+		}
+
+		var next **node
+
+		switch {
+		case strings.HasPrefix(segment, ParamPrefix):
+			next = &current.param
+		case segment == Wildcard:
+			next = &current.wildcard
+		default:
+			child := current.static[segment]
+
+			if child == nil && create {
+				child = newNode()
+				current.static[segment] = child
+			}
+
+			if child == nil {
+				return nil, names, nil
+			}
+
+			current = child
+			continue
+		}
+
+		if *next == nil {
+			if !create {
+				return nil, names, nil
+			}
+
+			*next = newNode()
+		}
+
+		current = *next
+	}
+
+	return &current.route, names, nil
+}
+
+// add registers the handler with the pattern under the identifier,
+// and returns ErrHandlerRegistered if the pattern has a handler with the same identifier.
+func (r *router) add(pattern, id string, handler EventHandler) error {
+	slot, names, err := r.slot(pattern, true)
+
+	if err != nil {
+		return err
+	}
+
+	if *slot == nil {
+		*slot = &route{
+			pattern: pattern,
+		}
+	}
+
+	handlers := (*slot).handlers
+
+	for _, h := range handlers {
+		if h.id == id {
+			return ErrHandlerRegistered
+		}
+	}
+
+	(*slot).handlers = append(handlers[:len(handlers):len(handlers)], namedHandler{id: id, handler: handler, names: names})
+
+	return nil
+}
+
+// remove removes the handlers of the pattern with the identifiers, or all of them if no identifier is given,
+// and returns ErrHandlerNotFound if any of them is not registered.
+// The nodes left empty are kept, as they are cheap and likely to be reused.
+func (r *router) remove(pattern string, ids ...string) error {
+	slot, _, err := r.slot(pattern, false)
+
+	if err != nil || slot == nil || *slot == nil {
+		return ErrHandlerNotFound
+	}
+
+	if len(ids) == 0 {
+		*slot = nil
+		return nil
+	}
+
+	handlers := (*slot).handlers
+
+	for _, id := range ids {
+		remaining := make([]namedHandler, 0, len(handlers))
+
+		for _, h := range handlers {
+			if h.id != id {
+				remaining = append(remaining, h)
+			}
+		}
+
+		if len(remaining) == len(handlers) {
+			return ErrHandlerNotFound
+		}
+
+		handlers = remaining
+	}
+
+	if len(handlers) == 0 {
+		*slot = nil
+	} else {
+		(*slot).handlers = handlers
+	}
+
+	return nil
+}
+
+// has reports whether the pattern has a handler.
+func (r *router) has(pattern string) bool {
+	slot, _, err := r.slot(pattern, false)
+
+	return err == nil && slot != nil && *slot != nil
+}
+
+// match returns the route of the (normalized) action, the values captured by its params in their order,
+// and the segments matched by the catch-all if any; see namedHandler.params.
+func (r *router) match(action string) (*route, []string, []string) {
+	if !strings.HasPrefix(action, "/") {
+		return nil, nil, nil
+	}
+
+	segments := splitAction(action)
+	values := make([]string, 0)
+
+	matched, rest := r.root.match(segments, &values)
+
+	if matched == nil {
+		return nil, nil, nil
+	}
+
+	return matched, values, rest
+}
+
+// match returns the route matching the segments under the node, collecting the values of the params,
+// and the segments matched by the catch-all if any.
+func (n *node) match(segments []string, values *[]string) (*route, []string) {
+	if len(segments) == 0 {
+		if n.route != nil {
+			return n.route, nil
+		}
+
+		if n.catchAll != nil {
+			return n.catchAll, []string{}
+		}
+
+		return nil, nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+
+	if child := n.static[segment]; child != nil {
+		if matched, remaining := child.match(rest, values); matched != nil {
+			return matched, remaining
+		}
+	}
+
+	if n.param != nil {
+		*values = append(*values, segment)
+
+		if matched, remaining := n.param.match(rest, values); matched != nil {
+			return matched, remaining
+		}
+
+		*values = (*values)[:len(*values)-1]
+	}
+
+	if n.wildcard != nil {
+		if matched, remaining := n.wildcard.match(rest, values); matched != nil {
+			return matched, remaining
+		}
+	}
+
+	if n.catchAll != nil {
+		return n.catchAll, segments
+	}
+
+	return nil, nil
+}
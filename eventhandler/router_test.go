@@ -0,0 +1,164 @@
+package eventhandler
+
+import (
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"testing"
+)
+
+// recordingHandler records the action pattern it is registered with, and the params it receives.
+type recordingHandler struct {
+	action string
+	routed *[]string
+	params *Params
+}
+
+func (h *recordingHandler) Action() string {
+	return h.action
+}
+
+func (h *recordingHandler) Handle(event pubsub.Event) error {
+	*h.routed = append(*h.routed, h.action)
+	*h.params = ParamsOf(event)
+
+	return nil
+}
+
+func newIncomingEvent(t *testing.T, action string) pubsub.Event {
+	event, err := pubsub.NewIncomingEvent(&pubsub.EventID{Topic: "router-topic"}, map[string]interface{}{
+		"action": action,
+	})
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	return event
+}
+
+func TestRegistry_RoutePatterns(t *testing.T) {
+	reg := NewRegistry()
+
+	routed := make([]string, 0)
+	params := Params{}
+
+	patterns := []string{
+		"/room/:roomId/message",
+		"/room/lobby/message",
+		"/room/*/typing",
+		"/room/:roomId/**",
+		"/user/*",
+		"/**",
+	}
+
+	for _, pattern := range patterns {
+		if err := reg.Register(&recordingHandler{action: pattern, routed: &routed, params: &params}); err != nil {
+			t.Fatalf("failed to register %s: %v", pattern, err)
+		}
+	}
+
+	cases := []struct {
+		action  string
+		pattern string
+		params  Params
+	}{
+		// static > param
+		{"/room/lobby/message", "/room/lobby/message", Params{}},
+		{"/room/42/message", "/room/:roomId/message", Params{"roomId": "42"}},
+		// param > wildcard
+		{"/room/42/typing", "/room/:roomId/**", Params{"roomId": "42", CatchAll: "typing"}},
+		// the static segment does not match the rest, so it backtracks to the param
+		{"/room/lobby/typing", "/room/:roomId/**", Params{"roomId": "lobby", CatchAll: "typing"}},
+		{"/room/42/member/joined", "/room/:roomId/**", Params{"roomId": "42", CatchAll: "member/joined"}},
+		{"/user/created", "/user/*", Params{}},
+		{"/user/created/twice", "/**", Params{CatchAll: "user/created/twice"}},
+		{"/", "/**", Params{CatchAll: ""}},
+	}
+
+	for _, c := range cases {
+		routed = routed[:0]
+
+		if err := reg.Route(newIncomingEvent(t, c.action)); err != nil {
+			t.Errorf("failed to route %s: %v", c.action, err)
+			continue
+		}
+
+		if len(routed) != 1 || routed[0] != c.pattern {
+			t.Errorf("expected %s to be routed to %s, got %v", c.action, c.pattern, routed)
+		}
+
+		if len(params) != len(c.params) {
+			t.Errorf("expected the params of %s to be %v, got %v", c.action, c.params, params)
+		}
+
+		for name, value := range c.params {
+			if params[name] != value {
+				t.Errorf("expected the params of %s to be %v, got %v", c.action, c.params, params)
+			}
+		}
+	}
+}
+
+func TestRegistry_WildcardPrecedence(t *testing.T) {
+	reg := NewRegistry()
+
+	routed := make([]string, 0)
+	params := Params{}
+
+	for _, pattern := range []string{"/room/*/typing", "/room/:roomId/message"} {
+		_ = reg.Register(&recordingHandler{action: pattern, routed: &routed, params: &params})
+	}
+
+	// the param matches the segment, but not the rest, so the wildcard is tried next
+	if err := reg.Route(newIncomingEvent(t, "/room/42/typing")); err != nil {
+		t.Fatalf("failed to route: %v", err)
+	}
+
+	if len(routed) != 1 || routed[0] != "/room/*/typing" {
+		t.Errorf("expected /room/*/typing, got %v", routed)
+	}
+
+	if err := reg.Route(newIncomingEvent(t, "/room/42/left")); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
+
+func TestRegistry_PatternRegistration(t *testing.T) {
+	reg := NewRegistry()
+
+	routed := make([]string, 0)
+	params := Params{}
+
+	_ = reg.Register(&recordingHandler{action: "/room/:roomId/message", routed: &routed, params: &params})
+
+	// the names of the params do not distinguish the patterns
+	err := reg.Register(&recordingHandler{action: "/room/:id/message", routed: &routed, params: &params})
+
+	if !errors.Is(err, ErrHandlerRegistered) {
+		t.Errorf("expected ErrHandlerRegistered, got %v", err)
+	}
+
+	if !reg.Has("/room/:roomId/message") || reg.Has("/room/42/message") || reg.Has("/room/:roomId") {
+		t.Errorf("expected only the registered pattern to exist")
+	}
+
+	for _, pattern := range []string{"room", "/room/**/message", "/room/:/message", "/room//message"} {
+		err := reg.Register(&recordingHandler{action: pattern, routed: &routed, params: &params})
+
+		if !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("expected ErrInvalidPattern of %s, got %v", pattern, err)
+		}
+	}
+
+	if err := reg.Unregister("/room/:roomId/message"); err != nil {
+		t.Errorf("failed to unregister: %v", err)
+	}
+
+	if err := reg.Unregister("/room/:roomId/message"); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+
+	if err := reg.Route(newIncomingEvent(t, "/room/42/message")); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+}
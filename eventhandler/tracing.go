@@ -0,0 +1,63 @@
+package eventhandler
+
+import (
+	"context"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/edgejumps/sportstalk-pubsub/eventhandler"
+
+// The attributes of the consumer spans, following the semantic conventions of messaging where applicable.
+const (
+	AttributeTopic   = attribute.Key("messaging.destination.name")
+	AttributeEntryID = attribute.Key("messaging.message.id")
+	AttributeAction  = attribute.Key("pubsub.event.action")
+	AttributePattern = attribute.Key("pubsub.event.pattern")
+)
+
+// startConsumerSpan starts a consumer span of the event within ctx via the global tracer provider of OpenTelemetry,
+// as a child of the trace context carried by the event, or of the span of ctx if the event carries none.
+func startConsumerSpan(ctx context.Context, event pubsub.Event) (context.Context, trace.Span) {
+	id := event.ID()
+
+	attributes := []attribute.KeyValue{
+		AttributeTopic.String(id.Topic),
+		AttributeAction.String(event.Action()),
+	}
+
+	if id.EntryID != "" {
+		attributes = append(attributes, AttributeEntryID.String(id.EntryID))
+	}
+
+	if id.Pattern != "" {
+		attributes = append(attributes, AttributePattern.String(id.Pattern))
+	}
+
+	if carried := event.Context(); trace.SpanContextFromContext(carried).IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, trace.SpanContextFromContext(carried))
+	}
+
+	if carried := baggage.FromContext(event.Context()); carried.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, carried)
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, event.Action(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// endSpan records the error of the handler, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
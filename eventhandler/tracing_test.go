@@ -0,0 +1,154 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+type failingHandler struct {
+	action string
+	err    error
+}
+
+func (f *failingHandler) Action() string {
+	return pubsub.NormalizeActionPath(f.action)
+}
+
+func (f *failingHandler) Handle(event pubsub.Event) error {
+	return f.err
+}
+
+func setupTracing(t *testing.T) (*tracetest.InMemoryExporter, trace.Tracer) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return exporter, provider.Tracer("publisher")
+}
+
+func TestRegistry_RouteTracing(t *testing.T) {
+	exporter, tracer := setupTracing(t)
+
+	ps := newMemoryStream("traced-topic")
+
+	err := ps.Subscribe(pubsub.NewTopic("traced-topic", string(pubsub.MinimumID)))
+
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "publish")
+
+	event, err := pubsub.NewOutgoingEvent(&pubsub.EventID{Topic: "traced-topic"}, "/traced", 0, nil)
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	err = ps.Publish(ctx, event)
+
+	if err != nil {
+		t.Fatalf("failed to publish event: %v", err)
+	}
+
+	parent.End()
+
+	var received pubsub.Event
+
+	select {
+	case received = <-ps.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+
+	_, _ = ps.Stop()
+
+	if received.Headers()["traceparent"] == "" {
+		t.Errorf("expected traceparent in the headers, got %v", received.Headers())
+	}
+
+	reg := NewRegistry()
+	handlerErr := errors.New("handler failed")
+
+	_ = reg.Register(&failingHandler{action: "/traced", err: handlerErr})
+
+	if err := reg.Route(received); !errors.Is(err, handlerErr) {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+
+	var consumer *tracetest.SpanStub
+
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind == trace.SpanKindConsumer {
+			consumer = &span
+		}
+	}
+
+	if consumer == nil {
+		t.Fatalf("expected a consumer span, got %v", exporter.GetSpans())
+	}
+
+	if consumer.Parent.TraceID() != parent.SpanContext().TraceID() || consumer.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected the consumer span to be a child of the publisher span")
+	}
+
+	if consumer.Name != "/traced" || consumer.Status.Code != codes.Error {
+		t.Errorf("expected a failed span named /traced, got %s with %v", consumer.Name, consumer.Status)
+	}
+
+	attributes := make(map[string]string)
+
+	for _, kv := range consumer.Attributes {
+		attributes[string(kv.Key)] = kv.Value.AsString()
+	}
+
+	if attributes[string(AttributeTopic)] != "traced-topic" || attributes[string(AttributeAction)] != "/traced" {
+		t.Errorf("expected the topic and action attributes, got %v", attributes)
+	}
+
+	if attributes[string(AttributeEntryID)] != received.ID().EntryID {
+		t.Errorf("expected the entry ID attribute to be %s, got %v", received.ID().EntryID, attributes)
+	}
+}
+
+func TestRegistry_RouteWithoutTraceContext(t *testing.T) {
+	exporter, _ := setupTracing(t)
+
+	event, err := pubsub.NewIncomingEvent(&pubsub.EventID{Topic: "mock-topic"}, map[string]interface{}{
+		"action": "/untraced",
+	})
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	if err := NewRegistry().Route(event); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+
+	if len(spans) != 1 || spans[0].Parent.IsValid() {
+		t.Errorf("expected one root span, got %v", spans)
+	}
+}
@@ -0,0 +1,83 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"reflect"
+)
+
+var ErrUnexpectedPayloadType = errors.New("unexpected payload type")
+
+// DecodeError reports the payload of an event that cannot be decoded as the type of a typed handler,
+// which is not a failure of the handler itself, as the handler is never invoked.
+type DecodeError struct {
+	Action string
+	Type   string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode the payload of %s as %s: %v", e.Action, e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedHandler is an EventHandler decoding the payload as T before handling it, see Typed.
+// It is also the EventDataBuilder of the action, so that Registry.BuildData only accepts a payload of T.
+type TypedHandler[T any] struct {
+	action string
+	handle func(ctx context.Context, event pubsub.Event, payload T) error
+}
+
+// Typed returns the handler of the action, receiving the context of the event (see pubsub.Event.Context())
+// and the payload decoded as T via pubsub.DecodePayload. A payload that cannot be decoded is reported as *DecodeError.
+// The events of the action are expected to be created via pubsub.NewTypedOutgoingEvent or Registry.BuildData.
+func Typed[T any](action string, handle func(ctx context.Context, event pubsub.Event, payload T) error) *TypedHandler[T] {
+	return &TypedHandler[T]{
+		action: action,
+		handle: handle,
+	}
+}
+
+func (h *TypedHandler[T]) Action() string {
+	return pubsub.NormalizeActionPath(h.action)
+}
+
+func (h *TypedHandler[T]) Handle(event pubsub.Event) error {
+	payload, err := pubsub.DecodePayload[T](event)
+
+	if err != nil {
+		return &DecodeError{
+			Action: event.Action(),
+			Type:   typeName[T](),
+			Err:    err,
+		}
+	}
+
+	return h.handle(event.Context(), event, payload)
+}
+
+// Build builds the event data of the action via pubsub.NewTypedEventData,
+// and returns ErrUnexpectedPayloadType if the payload is not of T.
+func (h *TypedHandler[T]) Build(payload interface{}, ttl int) (pubsub.EventData, error) {
+	typed, ok := payload.(T)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %T, expected %s", ErrUnexpectedPayloadType, payload, typeName[T]())
+	}
+
+	return h.BuildTyped(typed, ttl)
+}
+
+// BuildTyped builds the event data of the action via pubsub.NewTypedEventData.
+func (h *TypedHandler[T]) BuildTyped(payload T, ttl int) (pubsub.EventData, error) {
+	return pubsub.NewTypedEventData(h.Action(), ttl, payload)
+}
+
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -0,0 +1,87 @@
+package eventhandler
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"testing"
+)
+
+type messageCreated struct {
+	RoomID string `json:"roomId"`
+	Text   string `json:"text"`
+}
+
+func TestTyped(t *testing.T) {
+	reg := NewRegistry()
+
+	var received messageCreated
+	invoked := 0
+
+	_ = reg.Register(Typed("/message/created", func(ctx context.Context, event pubsub.Event, payload messageCreated) error {
+		invoked++
+		received = payload
+
+		return nil
+	}))
+
+	data, err := reg.BuildData("/message/created", messageCreated{RoomID: "42", Text: "hello"}, 1)
+
+	if err != nil {
+		t.Fatalf("failed to build data: %v", err)
+	}
+
+	var values map[string]interface{}
+
+	if err := data.NormalizeInto(&values); err != nil {
+		t.Fatalf("failed to normalize data: %v", err)
+	}
+
+	event, err := pubsub.NewIncomingEvent(&pubsub.EventID{Topic: "typed"}, values)
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	if err := reg.Route(event); err != nil {
+		t.Fatalf("failed to route: %v", err)
+	}
+
+	if invoked != 1 || received.RoomID != "42" || received.Text != "hello" {
+		t.Errorf("expected the decoded payload, got %v", received)
+	}
+
+	if _, err := reg.BuildData("/message/created", "hello", 1); !errors.Is(err, ErrUnexpectedPayloadType) {
+		t.Errorf("expected ErrUnexpectedPayloadType, got %v", err)
+	}
+}
+
+func TestTyped_DecodeError(t *testing.T) {
+	invoked := false
+
+	handler := Typed("/message/created", func(ctx context.Context, event pubsub.Event, payload messageCreated) error {
+		invoked = true
+		return nil
+	})
+
+	event, err := pubsub.NewIncomingEvent(&pubsub.EventID{Topic: "typed"}, map[string]interface{}{
+		"action":  "/message/created",
+		"payload": "not json",
+	})
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	err = handler.Handle(event)
+
+	var decodeErr *DecodeError
+
+	if !errors.As(err, &decodeErr) || decodeErr.Type != "eventhandler.messageCreated" {
+		t.Errorf("expected DecodeError, got %v", err)
+	}
+
+	if invoked {
+		t.Errorf("expected the handler not to be invoked")
+	}
+}
@@ -28,7 +28,7 @@ func main() {
 	s := make(chan struct{})
 
 	go func() {
-		sema := make(chan os.Signal)
+		sema := make(chan os.Signal, 1)
 
 		signal.Notify(sema, os.Interrupt, os.Kill)
 
@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"github.com/redis/go-redis/v9"
+	"strings"
+)
+
+// clusterSlots is the number of hash slots of Redis Cluster.
+const clusterSlots = 16384
+
+// crc16Table is the lookup table of CRC16-CCITT (XMODEM), the checksum Redis Cluster hashes keys with.
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+
+	for i := range table {
+		crc := uint16(i) << 8
+
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+
+		table[i] = crc
+	}
+
+	return table
+}()
+
+func crc16(key string) uint16 {
+	crc := uint16(0)
+
+	for i := 0; i < len(key); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^key[i]]
+	}
+
+	return crc
+}
+
+// keySlot returns the hash slot of the key (or channel) in Redis Cluster.
+// If the key contains a non-empty hash tag, e.g. "{user}.events", only the tag is hashed,
+// so that the keys sharing a tag are always in the same slot.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key)) % clusterSlots
+}
+
+// slotGroups groups the keys by their hash slot if the client talks to a Redis Cluster,
+// as a multi-key command (e.g. XREAD or SSUBSCRIBE) must not cross slots there.
+// Otherwise, all keys are in one group. The keys keep their order within a group.
+func slotGroups(c redis.UniversalClient, keys []string) [][]string {
+	if _, ok := c.(*redis.ClusterClient); !ok {
+		return [][]string{keys}
+	}
+
+	groups := make([][]string, 0)
+	indexes := make(map[int]int)
+
+	for _, key := range keys {
+		slot := keySlot(key)
+
+		index, ok := indexes[slot]
+
+		if !ok {
+			index = len(groups)
+			indexes[slot] = index
+			groups = append(groups, make([]string, 0))
+		}
+
+		groups[index] = append(groups[index], key)
+	}
+
+	return groups
+}
+
+// groupTopicsBySlot groups the topics as slotGroups does with their names.
+func groupTopicsBySlot(c redis.UniversalClient, topics []Topic) [][]Topic {
+	byName := make(map[string]Topic)
+	names := make([]string, 0)
+
+	for _, topic := range topics {
+		byName[topic.Name()] = topic
+		names = append(names, topic.Name())
+	}
+
+	groups := make([][]Topic, 0)
+
+	for _, keys := range slotGroups(c, names) {
+		group := make([]Topic, 0)
+
+		for _, key := range keys {
+			group = append(group, byName[key])
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups
+}
@@ -0,0 +1,217 @@
+package pubsub
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeySlot(t *testing.T) {
+	cases := map[string]int{
+		"foo":   12182,
+		"bar":   5061,
+		"hello": 866,
+	}
+
+	for key, slot := range cases {
+		if got := keySlot(key); got != slot {
+			t.Errorf("Expected slot of %s to be %d, got %d", key, slot, got)
+		}
+	}
+
+	if keySlot("{user1000}.following") != keySlot("user1000") || keySlot("{user1000}.followers") != keySlot("user1000") {
+		t.Errorf("Expected the keys sharing a hash tag to be in the slot of the tag")
+	}
+
+	if keySlot("foo{bar}{zap}") != keySlot("bar") {
+		t.Errorf("Expected only the first hash tag to be hashed")
+	}
+
+	// an empty hash tag does not count, so the whole key is hashed
+	if keySlot("foo{}{bar}") == keySlot("bar") {
+		t.Errorf("Expected the empty hash tag to be ignored")
+	}
+}
+
+func TestSlotGroups(t *testing.T) {
+	keys := []string{"foo", "{foo}.events", "bar", "{bar}.events", "hello"}
+
+	groups := slotGroups(redis.NewClient(&redis.Options{}), keys)
+
+	if len(groups) != 1 || len(groups[0]) != len(keys) {
+		t.Errorf("Expected all keys in one group without a cluster client, got %v", groups)
+	}
+
+	groups = slotGroups(redis.NewClusterClient(&redis.ClusterOptions{}), keys)
+
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %v", groups)
+	}
+
+	for _, group := range groups {
+		for _, key := range group {
+			if keySlot(key) != keySlot(group[0]) {
+				t.Errorf("Expected the keys of group %v to share a slot", group)
+			}
+		}
+	}
+}
+
+// commandRecorder records the keys of every XREAD sent by the client.
+type commandRecorder struct {
+	mu    *sync.Mutex
+	reads [][]string
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "xread" {
+			r.record(cmd.Args())
+		}
+
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (r *commandRecorder) record(args []interface{}) {
+	keys := make([]string, 0)
+
+	// XREAD [COUNT count] [BLOCK ms] STREAMS key [key ...] id [id ...]
+	for i, arg := range args {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "streams") {
+			streams := args[i+1:]
+
+			for _, key := range streams[:len(streams)/2] {
+				keys = append(keys, key.(string))
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reads = append(r.reads, keys)
+}
+
+func TestStreamPubSub_Cluster(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// miniredis serves all slots, as a single-node cluster
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{server.Addr()},
+	})
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	recorder := &commandRecorder{mu: &sync.Mutex{}}
+	client.AddHook(recorder)
+
+	streams := []string{"foo", "{foo}.events", "bar"}
+
+	for _, stream := range streams {
+		err := client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: stream,
+			Values: map[string]interface{}{
+				EventActionKey:    "/" + stream,
+				EventTimestampKey: time.Now().UnixMilli(),
+			},
+		}).Err()
+
+		if err != nil {
+			t.Fatalf("Error adding stream entry: %v", err)
+		}
+	}
+
+	ps := WithStream(client, 0, WithStreamRead(20*time.Millisecond, 0))
+
+	topics := make([]Topic, 0)
+
+	for _, stream := range streams {
+		topics = append(topics, NewTopic(stream, string(MinimumID)))
+	}
+
+	err := ps.Subscribe(topics...)
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	received := make(map[string]bool)
+
+	for range streams {
+		select {
+		case e := <-ps.Events():
+			received[e.ID().Topic] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event, received %v", received)
+		}
+	}
+
+	_, _ = ps.Stop()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if len(recorder.reads) == 0 {
+		t.Fatalf("Expected XREAD to be recorded")
+	}
+
+	for _, keys := range recorder.reads {
+		for _, key := range keys {
+			if keySlot(key) != keySlot(keys[0]) {
+				t.Errorf("Expected XREAD of keys in one slot, got %v", keys)
+			}
+		}
+	}
+}
+
+func TestRedisPubSub_Sharded(t *testing.T) {
+	requireRedis(t)
+
+	ps := New(rdb, WithShardedPubSub())
+
+	err := ps.Subscribe(NewTopic("{sharded}.first", ""), NewTopic("{sharded}.second", ""))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "{sharded}.second",
+	}, "/sharded", 0, nil)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.ID().Topic != "{sharded}.second" || e.Action() != "/sharded" {
+			t.Errorf("Expected /sharded from {sharded}.second, got %s from %s", e.Action(), e.ID().Topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	_, _ = ps.Stop()
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDeadLetterSuffix = ":dlq"
+
+	// deadLetterKeyPrefix prefixes the fields added to the dead-lettered entry,
+	// so that they would not conflict with the original fields of a stream entry.
+	deadLetterKeyPrefix = "dlq_"
+
+	DeadLetterTopicKey    = deadLetterKeyPrefix + "topic"
+	DeadLetterEntryIDKey  = deadLetterKeyPrefix + "entry_id"
+	DeadLetterErrorKey    = deadLetterKeyPrefix + "error"
+	DeadLetterFailedAtKey = deadLetterKeyPrefix + "failed_at"
+	DeadLetterRawKey      = deadLetterKeyPrefix + "raw"
+)
+
+var (
+	ErrMaxDeliveriesExceeded = errors.New("max deliveries exceeded")
+	ErrInvalidDeadLetter     = errors.New("invalid dead letter")
+)
+
+// DeadLetter represents an entry in a dead-letter stream.
+type DeadLetter struct {
+	// ID is the entry ID in the dead-letter stream.
+	ID string
+
+	// Topic and EntryID identify where the original entry came from.
+	// EntryID is empty for Redis PubSub.
+	Topic   string
+	EntryID string
+
+	// Error is the reason why the entry was dead-lettered.
+	Error string
+
+	// FailedAt is the time when the entry was dead-lettered.
+	// It is unix milliseconds since epoch.
+	FailedAt int64
+
+	// Values are the original fields of a Redis Stream entry.
+	Values map[string]interface{}
+
+	// Raw is the original payload of a Redis PubSub message.
+	Raw string
+}
+
+// DeadLetterTopic returns the name of the dead-letter stream of the topic.
+// An empty suffix means DefaultDeadLetterSuffix.
+func DeadLetterTopic(topic, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultDeadLetterSuffix
+	}
+
+	return topic + suffix
+}
+
+// ListDeadLetters lists at most count entries of the dead-letter stream, starting from the oldest one.
+// count <= 0 lists all entries.
+func ListDeadLetters(ctx context.Context, c redis.UniversalClient, dlq string, count int64) ([]DeadLetter, error) {
+	var messages []redis.XMessage
+	var err error
+
+	if count > 0 {
+		messages, err = c.XRangeN(ctx, dlq, "-", "+", count).Result()
+	} else {
+		messages, err = c.XRange(ctx, dlq, "-", "+").Result()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0)
+
+	for _, msg := range messages {
+		letters = append(letters, parseDeadLetter(msg))
+	}
+
+	return letters, nil
+}
+
+// RedriveDeadLetter publishes the original entry back into its source topic, and removes it from the dead-letter stream.
+// A Redis Stream entry is re-added with a new entry ID, and a Redis PubSub message is re-published
+// (via SPUBLISH with WithShardedPubSub). On a Redis Cluster, the two steps are only atomic if
+// the topic and the dead-letter stream share a hash tag.
+func RedriveDeadLetter(ctx context.Context, c redis.UniversalClient, dlq string, letter DeadLetter, opts ...Option) error {
+
+	if letter.Topic == "" || letter.ID == "" {
+		return ErrInvalidDeadLetter
+	}
+
+	o := newOptions(opts...)
+
+	_, err := c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if letter.Raw != "" && o.sharded {
+			pipe.SPublish(ctx, letter.Topic, letter.Raw)
+		} else if letter.Raw != "" {
+			pipe.Publish(ctx, letter.Topic, letter.Raw)
+		} else {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: letter.Topic,
+				ID:     string(AutoGeneratedID),
+				Values: letter.Values,
+			})
+		}
+
+		pipe.XDel(ctx, dlq, letter.ID)
+
+		return nil
+	})
+
+	return err
+}
+
+// writeDeadLetter adds the letter into the dead-letter stream, keeping the original fields of the entry.
+func writeDeadLetter(ctx context.Context, c redis.UniversalClient, dlq string, letter *DeadLetter) error {
+	values := make(map[string]interface{})
+
+	for key, value := range letter.Values {
+		values[key] = value
+	}
+
+	values[DeadLetterTopicKey] = letter.Topic
+	values[DeadLetterEntryIDKey] = letter.EntryID
+	values[DeadLetterErrorKey] = letter.Error
+	values[DeadLetterFailedAtKey] = time.Now().UnixMilli()
+
+	if letter.Raw != "" {
+		values[DeadLetterRawKey] = letter.Raw
+	}
+
+	return c.XAdd(ctx, &redis.XAddArgs{
+		Stream: dlq,
+		ID:     string(AutoGeneratedID),
+		Values: values,
+	}).Err()
+}
+
+func parseDeadLetter(msg redis.XMessage) DeadLetter {
+	letter := DeadLetter{
+		ID:     msg.ID,
+		Values: make(map[string]interface{}),
+	}
+
+	for key, value := range msg.Values {
+
+		str, _ := value.(string)
+
+		switch key {
+		case DeadLetterTopicKey:
+			letter.Topic = str
+		case DeadLetterEntryIDKey:
+			letter.EntryID = str
+		case DeadLetterErrorKey:
+			letter.Error = str
+		case DeadLetterFailedAtKey:
+			letter.FailedAt = ParseTimestamp(str)
+		case DeadLetterRawKey:
+			letter.Raw = str
+		default:
+			if !strings.HasPrefix(key, deadLetterKeyPrefix) {
+				letter.Values[key] = value
+			}
+		}
+	}
+
+	return letter
+}
@@ -0,0 +1,243 @@
+package pubsub
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func waitDeadLetters(t *testing.T, dlq string, list func() ([]DeadLetter, error)) []DeadLetter {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		letters, err := list()
+
+		if err != nil {
+			t.Fatalf("Error listing dead letters: %v", err)
+		}
+
+		if len(letters) > 0 {
+			return letters
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Timeout waiting for dead letters in %s", dlq)
+
+	return nil
+}
+
+func TestStreamPubSub_DeadLetter(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	malformedID, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "dlq-stream",
+		Values: map[string]interface{}{"foo": "bar"},
+	}).Result()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	ps := WithStream(client, 0, WithDeadLetter("", 0))
+
+	err = ps.Subscribe(NewTopic("dlq-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "dlq-stream",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(ctx, event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.Action() != "/custom" {
+			t.Errorf("Expected action to be '/custom', got '%s'", e.Action())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	dlq := DeadLetterTopic("dlq-stream", "")
+
+	letters := waitDeadLetters(t, dlq, func() ([]DeadLetter, error) {
+		return ListDeadLetters(ctx, client, dlq, 0)
+	})
+
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+
+	letter := letters[0]
+
+	if letter.Topic != "dlq-stream" || letter.EntryID != malformedID {
+		t.Errorf("Expected dead letter from dlq-stream/%s, got %s/%s", malformedID, letter.Topic, letter.EntryID)
+	}
+
+	if letter.Error != ErrUnknownEventAction.Error() {
+		t.Errorf("Expected error to be '%v', got '%s'", ErrUnknownEventAction, letter.Error)
+	}
+
+	if letter.Values["foo"] != "bar" {
+		t.Errorf("Expected original values to be kept, got %v", letter.Values)
+	}
+
+	if letter.FailedAt == 0 {
+		t.Errorf("Expected failed time to be set")
+	}
+
+	ps.Stop()
+
+	err = RedriveDeadLetter(ctx, client, dlq, letter)
+
+	if err != nil {
+		t.Errorf("Error re-driving dead letter: %v", err)
+	}
+
+	remaining, err := ListDeadLetters(ctx, client, dlq, 0)
+
+	if err != nil {
+		t.Errorf("Error listing dead letters: %v", err)
+	}
+
+	if len(remaining) != 0 {
+		t.Errorf("Expected dead letter to be removed, got %d", len(remaining))
+	}
+
+	entries, err := client.XRevRangeN(ctx, "dlq-stream", "+", "-", 1).Result()
+
+	if err != nil {
+		t.Errorf("Error reading stream: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Values["foo"] != "bar" {
+		t.Errorf("Expected dead letter to be re-added to the source stream, got %v", entries)
+	}
+}
+
+func TestStreamPubSub_DeadLetterMaxDeliveries(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	crashed := WithStream(client, 0, WithConsumerGroup("dlq-group", "consumer-crashed"))
+
+	err := crashed.Subscribe(NewTopic("dlq-group-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "dlq-group-stream",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = crashed.Publish(ctx, event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case <-crashed.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	crashed.Stop()
+
+	ps := WithStream(client, 0,
+		WithConsumerGroup("dlq-group", "consumer-alive"),
+		WithPendingReclaim(10*time.Millisecond, 10*time.Millisecond),
+		WithDeadLetter(":failed", 1),
+	)
+
+	err = ps.Subscribe(NewTopic("dlq-group-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	dlq := DeadLetterTopic("dlq-group-stream", ":failed")
+
+	letters := waitDeadLetters(t, dlq, func() ([]DeadLetter, error) {
+		return ListDeadLetters(ctx, client, dlq, 1)
+	})
+
+	if letters[0].Error != ErrMaxDeliveriesExceeded.Error() {
+		t.Errorf("Expected error to be '%v', got '%s'", ErrMaxDeliveriesExceeded, letters[0].Error)
+	}
+
+	select {
+	case e := <-ps.Events():
+		t.Errorf("Unexpected event delivered: %v", e.ID())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pending, err := client.XPending(ctx, "dlq-group-stream", "dlq-group").Result()
+
+	if err != nil {
+		t.Errorf("Error getting pending entries: %v", err)
+	}
+
+	if pending.Count != 0 {
+		t.Errorf("Expected dead letter to be acknowledged, got %d pending", pending.Count)
+	}
+
+	ps.Stop()
+}
+
+func TestRedisPubSub_DeadLetter(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	ps := New(client, WithDeadLetter("", 0))
+
+	err := ps.Subscribe(NewTopic("dlq-channel", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	err = client.Publish(ctx, "dlq-channel", "not a json").Err()
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	dlq := DeadLetterTopic("dlq-channel", "")
+
+	letters := waitDeadLetters(t, dlq, func() ([]DeadLetter, error) {
+		return ListDeadLetters(ctx, client, dlq, 0)
+	})
+
+	if letters[0].Topic != "dlq-channel" || letters[0].Raw != "not a json" {
+		t.Errorf("Expected raw payload of dlq-channel, got %+v", letters[0])
+	}
+
+	err = RedriveDeadLetter(ctx, client, dlq, letters[0])
+
+	if err != nil {
+		t.Errorf("Error re-driving dead letter: %v", err)
+	}
+
+	ps.Stop()
+}
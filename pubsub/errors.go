@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ParseError reports an entry that cannot be parsed as Event via NewIncomingEvent.
+// EntryID is empty for Redis PubSub.
+type ParseError struct {
+	Topic   string
+	EntryID string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse entry [%s] of %s: %v", e.EntryID, e.Topic, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError reports a failed command to the server, e.g. XACK or XAUTOCLAIM.
+// A lost connection is reported as DisconnectedError instead.
+type TransportError struct {
+	Op     string
+	Topics []string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("failed to %s [%s]: %v", e.Op, strings.Join(e.Topics, ", "), e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError reports a panic recovered in a worker, with the stack trace where it happened.
+// The worker stops consuming after the panic.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic recovered: %v", e.Value)
+}
+
+// errorChannel is the buffered channel behind Errors() of the Redis backends.
+// Reporting never blocks, and a report after the channel is closed on Stop is dropped instead of panicking.
+type errorChannel struct {
+	ch chan error
+
+	mu *sync.RWMutex
+
+	closed bool
+}
+
+func newErrorChannel() *errorChannel {
+	return &errorChannel{
+		ch: make(chan error, errorBufferSize),
+		mu: &sync.RWMutex{},
+	}
+}
+
+// report sends the error without blocking; it would be dropped if the channel is full or closed.
+func (c *errorChannel) report(err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return
+	}
+
+	reportError(c.ch, err)
+}
+
+func (c *errorChannel) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	close(c.ch)
+}
+
+// reportError sends the error to errs without blocking the caller.
+// The error would be dropped if errs is nil or full.
+func reportError(errs chan<- error, err error) {
+	if errs == nil || err == nil {
+		return
+	}
+
+	select {
+	case errs <- err:
+	default:
+	}
+}
@@ -0,0 +1,199 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+// panicTopic panics when the worker syncs its offset.
+type panicTopic struct {
+	Topic
+}
+
+func (t *panicTopic) SyncOffset(offset string) {
+	panic("sync offset")
+}
+
+func TestStreamPubSub_Errors(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	malformedID, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "errors-stream",
+		Values: map[string]interface{}{"foo": "bar"},
+	}).Result()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	ps := WithStream(client, 0, WithReconnectBackoff(10*time.Millisecond, 10*time.Millisecond))
+
+	err = ps.Subscribe(NewTopic("errors-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	parseErr := waitError[*ParseError](t, ps.Errors())
+
+	if parseErr.Topic != "errors-stream" || parseErr.EntryID != malformedID {
+		t.Errorf("Expected parse error of errors-stream/%s, got %s/%s", malformedID, parseErr.Topic, parseErr.EntryID)
+	}
+
+	if !errors.Is(parseErr, ErrUnknownEventAction) {
+		t.Errorf("Expected parse error to wrap ErrUnknownEventAction, got %v", parseErr.Err)
+	}
+
+	// the server rejects the invalid offset on every read
+	err = ps.Subscribe(NewTopic("errors-invalid-offset", "invalid"))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	transportErr := waitError[*TransportError](t, ps.Errors())
+
+	if transportErr.Op != "XREAD" {
+		t.Errorf("Expected transport error of XREAD, got %s", transportErr.Op)
+	}
+
+	_, err = ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping pubsub: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case _, ok := <-ps.Errors():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Expected error channel to be closed")
+		}
+	}
+}
+
+func TestStreamPubSub_PanicError(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := WithStream(client, 0)
+
+	err := ps.Subscribe(&panicTopic{Topic: NewTopic("panic-stream", "")})
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	err = client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: "panic-stream",
+		Values: map[string]interface{}{"foo": "bar"},
+	}).Err()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	panicErr := waitError[*PanicError](t, ps.Errors())
+
+	if panicErr.Value != "sync offset" {
+		t.Errorf("Expected panic value to be 'sync offset', got %v", panicErr.Value)
+	}
+
+	if len(panicErr.Stack) == 0 {
+		t.Errorf("Expected stack trace to be reported")
+	}
+
+	ps.Stop()
+}
+
+func TestRedisPubSub_Errors(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	ps := New(client)
+
+	err := ps.Subscribe(NewTopic("errors-channel", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	// nobody reads the error channel, so the worker must keep delivering after it is full
+	for i := 0; i < errorBufferSize*2; i++ {
+		err = client.Publish(ctx, "errors-channel", fmt.Sprintf("malformed-%d", i)).Err()
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "errors-channel",
+	}, "/custom", 0, nil)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(ctx, event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.Action() != "/custom" {
+			t.Errorf("Expected action to be '/custom', got '%s'", e.Action())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	if len(ps.Errors()) != errorBufferSize {
+		t.Errorf("Expected error channel to be full, got %d errors", len(ps.Errors()))
+	}
+
+	parseErr := waitError[*ParseError](t, ps.Errors())
+
+	if parseErr.Topic != "errors-channel" {
+		t.Errorf("Expected parse error of errors-channel, got %s", parseErr.Topic)
+	}
+
+	ps.Stop()
+}
+
+func TestRedisPubSub_WithErrors(t *testing.T) {
+	client := newMiniRedis(t)
+
+	errs := make(chan error, 1)
+
+	// the given channel receives the errors besides Errors()
+	ps := New(client, WithErrors(errs))
+
+	err := ps.Subscribe(NewTopic("errors-given", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	err = client.Publish(context.Background(), "errors-given", "malformed").Err()
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	waitError[*ParseError](t, ps.Errors())
+	waitError[*ParseError](t, errs)
+
+	ps.Stop()
+}
@@ -1,5 +1,10 @@
 package pubsub
 
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+)
+
 // Event represents an event that is sent to/from a topic.
 // UnifiedPubSub would drop the received data if it cannot be parsed as Event via NewIncomingEvent.
 // Users should ensure the data can ben converted to Event via NewOutgoingEvent when publishing.
@@ -9,6 +14,10 @@ type Event interface {
 	// including the topic (where the event is sent to/from) and the entry ID if available.
 	ID() EventID
 
+	// Context returns the context carrying the trace context (e.g. W3C traceparent) extracted from the headers of an incoming event,
+	// so that the consumer spans can be linked to the publisher. It returns context.Background() for an outgoing event.
+	Context() context.Context
+
 	EventData
 }
 
@@ -19,13 +28,38 @@ type Event interface {
 // For Redis PubSub, EntryID is ignored;
 // For Kafka, EntryID is used as the message key;
 // For Redis Stream, EntryID is used as the stream entry ID (auto-generated if not provided).
+//
+// Pattern is the pattern of the PatternTopic matched by Topic for the incoming events, and empty otherwise.
 type EventID struct {
 	EntryID string
 	Topic   string
+	Pattern string
+}
+
+// AckableEvent represents an event delivered by a consumer group, e.g. Redis Stream with WithConsumerGroup.
+// The event stays pending in the group until it is acknowledged.
+type AckableEvent interface {
+	Event
+
+	// Ack acknowledges the event, so that it would not be delivered again.
+	Ack(ctx context.Context) error
+
+	// DeliveryCount returns how many times the event has been delivered within the group, including this delivery.
+	DeliveryCount() int64
+}
+
+// Ack acknowledges the event if it is an AckableEvent; otherwise, it does nothing.
+func Ack(ctx context.Context, event Event) error {
+	if e, ok := event.(AckableEvent); ok {
+		return e.Ack(ctx)
+	}
+
+	return nil
 }
 
 type eventImpl struct {
-	id *EventID
+	id  *EventID
+	ctx context.Context
 	EventData
 }
 
@@ -33,11 +67,16 @@ func (e *eventImpl) ID() EventID {
 	return *e.id
 }
 
+func (e *eventImpl) Context() context.Context {
+	return e.ctx
+}
+
 // NewIncomingEvent parses the given payload and creates a new incoming event with the given ID.
 // The given data must conform to the expected format:
 // - []byte -> unmarshal to map[string]interface{}
 // - string -> []byte(string) -> unmarshal to map[string]interface{}
 // - map[string]interface{} -> must have EventActionKey, and optional EventTTLKey, EventPayloadKey, EventTimestampKey
+// The trace context in the headers is extracted via the global propagator of OpenTelemetry, see Event.Context().
 func NewIncomingEvent(id *EventID, data interface{}) (Event, error) {
 	parsed, err := ParseIncomingEventData(data)
 
@@ -47,6 +86,7 @@ func NewIncomingEvent(id *EventID, data interface{}) (Event, error) {
 
 	return &eventImpl{
 		id:        id,
+		ctx:       extractTraceContext(parsed),
 		EventData: parsed,
 	}, nil
 }
@@ -56,9 +96,10 @@ func NewIncomingEvent(id *EventID, data interface{}) (Event, error) {
 // - struct/map -> json.Marshal -> []byte -> string
 // - []byte -> string
 // - string -> string
-func NewOutgoingEvent(id *EventID, action string, ttl int, payload interface{}) (Event, error) {
+// The headers can be set via WithHeaders or WithHeader.
+func NewOutgoingEvent(id *EventID, action string, ttl int, payload interface{}, opts ...EventDataOption) (Event, error) {
 
-	data, err := NewEventData(action, ttl, payload)
+	data, err := NewEventData(action, ttl, payload, opts...)
 
 	if err != nil {
 		return nil, err
@@ -66,6 +107,43 @@ func NewOutgoingEvent(id *EventID, action string, ttl int, payload interface{})
 
 	return &eventImpl{
 		id:        id,
+		ctx:       context.Background(),
+		EventData: data,
+	}, nil
+}
+
+type streamGroupEvent struct {
+	Event
+
+	client     redis.UniversalClient
+	group      string
+	deliveries int64
+}
+
+func (e *streamGroupEvent) Ack(ctx context.Context) error {
+	id := e.ID()
+
+	return e.client.XAck(ctx, id.Topic, e.group, id.EntryID).Err()
+}
+
+func (e *streamGroupEvent) DeliveryCount() int64 {
+	return e.deliveries
+}
+
+// withHeaders returns a copy of the event with the headers added, which override the existing ones of the same keys.
+func withHeaders(event Event, headers map[string]string) (Event, error) {
+	data, err := buildEventData(event.Action(), event.TTL(), event.Timestamp(), event.RawPayload(),
+		WithHeaders(event.Headers()), WithHeaders(headers))
+
+	if err != nil {
+		return nil, err
+	}
+
+	id := event.ID()
+
+	return &eventImpl{
+		id:        &id,
+		ctx:       event.Context(),
 		EventData: data,
 	}, nil
 }
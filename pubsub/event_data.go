@@ -3,12 +3,12 @@ package pubsub
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/edgejumps/sportstalk-common-utils/logger"
+	"strings"
 	"time"
 )
 
 // EventData represents the data associated with an event.
-// It contains the action, time-to-live (TTL), and the payload.
+// It contains the action, time-to-live (TTL), the headers, and the payload.
 // The payload can be of type map[string]interface{}, []byte, or string.
 type EventData interface {
 
@@ -27,6 +27,10 @@ type EventData interface {
 	// It is unix milliseconds since epoch.
 	Timestamp() int64
 
+	// Headers returns the metadata associated with the event, e.g. correlation ID, tenant ID, or content type.
+	// The headers travel beside the payload, and it returns nil if the event has no headers.
+	Headers() map[string]string
+
 	// UnmarshalPayload deserializes the RawPayload into the target.
 	// The target must be a pointer to a struct, similar to json.Unmarshal.
 	// Typically, it is used for deserializing the payload into a struct.
@@ -42,10 +46,13 @@ type EventData interface {
 	// 	"action": "/event/action",
 	// 	"ttl": 1,
 	// 	"timestamp": 1630000000000,
+	// 	"headers": {"key": "value"},
 	// 	"payload": <raw payload>
 	// }
 	// ```
 	// then, marshal it into the target.
+	// For *map[string]interface{}, the headers are flattened into the fields prefixed with EventHeaderPrefix instead,
+	// as the values of a Redis Stream entry must be flat.
 	// e.g., Redis Pub/Sub stores the value as string.
 	// Redis Stream stores the value as map[string]interface{}.
 	// kafka stores the value as []byte.
@@ -60,6 +67,42 @@ type baseEventData struct {
 	action    string
 	ttl       int
 	timestamp int64
+	headers   map[string]string
+}
+
+// EventDataOption configures the event data created by NewEventData or NewOutgoingEvent.
+type EventDataOption func(*baseEventData)
+
+// WithHeaders adds the given headers to the event data.
+func WithHeaders(headers map[string]string) EventDataOption {
+	return func(e *baseEventData) {
+		for key, value := range headers {
+			WithHeader(key, value)(e)
+		}
+	}
+}
+
+// WithHeader adds a header to the event data. The header with an empty key is ignored.
+func WithHeader(key, value string) EventDataOption {
+	return func(e *baseEventData) {
+		if key == "" {
+			return
+		}
+
+		if e.headers == nil {
+			e.headers = make(map[string]string)
+		}
+
+		e.headers[key] = value
+	}
+}
+
+// WithTimestamp overrides the creation time of the event data (unix milliseconds since epoch),
+// e.g. to keep the original timestamp when an incoming event is re-published.
+func WithTimestamp(timestamp int64) EventDataOption {
+	return func(e *baseEventData) {
+		e.timestamp = timestamp
+	}
 }
 
 func (e *baseEventData) Action() string {
@@ -74,6 +117,10 @@ func (e *baseEventData) Timestamp() int64 {
 	return e.timestamp
 }
 
+func (e *baseEventData) Headers() map[string]string {
+	return e.headers
+}
+
 func (e *baseEventData) format(payload interface{}) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
@@ -117,8 +164,16 @@ func (e *baseEventData) normalize(target interface{}, payload interface{}) error
 
 	switch target := target.(type) {
 	case *map[string]interface{}:
+		for key, value := range e.headers {
+			jsonData[EventHeaderPrefix+key] = value
+		}
+
 		*target = jsonData
 	case *string:
+		if len(e.headers) > 0 {
+			jsonData[EventHeadersKey] = e.headers
+		}
+
 		bytes, err := json.Marshal(jsonData)
 
 		if err != nil {
@@ -128,6 +183,10 @@ func (e *baseEventData) normalize(target interface{}, payload interface{}) error
 		*target = string(bytes)
 
 	case *[]byte:
+		if len(e.headers) > 0 {
+			jsonData[EventHeadersKey] = e.headers
+		}
+
 		bytes, err := json.Marshal(jsonData)
 
 		if err != nil {
@@ -204,8 +263,9 @@ func (e *stringEventData) RawPayload() interface{} {
 // - struct/map -> json.Marshal -> []byte -> string
 // - []byte -> string
 // - string -> string
-func NewEventData(action string, ttl int, payload interface{}) (EventData, error) {
-	return buildEventData(action, ttl, time.Now().UnixMilli(), payload)
+// The headers can be set via WithHeaders or WithHeader.
+func NewEventData(action string, ttl int, payload interface{}, opts ...EventDataOption) (EventData, error) {
+	return buildEventData(action, ttl, time.Now().UnixMilli(), payload, opts...)
 }
 
 // ParseIncomingEventData parses the incoming data into an EventData.
@@ -213,6 +273,7 @@ func NewEventData(action string, ttl int, payload interface{}) (EventData, error
 // - []byte -> unmarshal to map[string]interface{}
 // - string -> []byte(string) -> unmarshal to map[string]interface{}
 // - map[string]interface{} -> must have EventActionKey, and optional EventTTLKey, EventPayloadKey, EventTimestampKey
+// The headers are parsed from EventHeadersKey, or the fields prefixed with EventHeaderPrefix; both are optional.
 func ParseIncomingEventData(data interface{}) (EventData, error) {
 
 	jsonMap := make(map[string]interface{})
@@ -243,26 +304,49 @@ func ParseIncomingEventData(data interface{}) (EventData, error) {
 
 	ttl := NormalizeTTL(jsonMap[EventTTLKey])
 
-	payload, ok := jsonMap[EventPayloadKey]
-
-	if !ok {
-		logger.Info("No data associated with event")
-	}
+	payload := jsonMap[EventPayloadKey]
 
 	timestamp := ParseTimestamp(jsonMap[EventTimestampKey])
 
-	fmt.Printf("action: %s, ttl: %d, timestamp: %d, payload: %v\n", action, ttl, timestamp, payload)
+	return buildEventData(action, ttl, timestamp, payload, WithHeaders(parseHeaders(jsonMap)))
+}
 
-	return buildEventData(action, ttl, timestamp, payload)
+// parseHeaders collects the headers of the envelope, which are either nested under EventHeadersKey (JSON),
+// or flattened into the fields prefixed with EventHeaderPrefix (Redis Stream).
+func parseHeaders(jsonMap map[string]interface{}) map[string]string {
+	headers := make(map[string]string)
+
+	switch nested := jsonMap[EventHeadersKey].(type) {
+	case map[string]interface{}:
+		for key, value := range nested {
+			headers[key] = fmt.Sprint(value)
+		}
+	case map[string]string:
+		for key, value := range nested {
+			headers[key] = value
+		}
+	}
+
+	for field, value := range jsonMap {
+		if key, ok := strings.CutPrefix(field, EventHeaderPrefix); ok {
+			headers[key] = fmt.Sprint(value)
+		}
+	}
+
+	return headers
 }
 
-func buildEventData(action string, ttl int, timestamp int64, payload interface{}) (EventData, error) {
+func buildEventData(action string, ttl int, timestamp int64, payload interface{}, opts ...EventDataOption) (EventData, error) {
 	base := baseEventData{
 		action:    NormalizeActionPath(action),
 		ttl:       NormalizeTTL(ttl),
 		timestamp: timestamp,
 	}
 
+	for _, opt := range opts {
+		opt(&base)
+	}
+
 	if payload == nil {
 		return &binaryEventData{
 			baseEventData: base,
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -330,6 +331,94 @@ func TestIncomingEvent_CustomString(t *testing.T) {
 
 }
 
+func TestEventData_Headers(t *testing.T) {
+	headers := map[string]string{
+		"correlation-id": "abc",
+		"tenant":         "acme",
+	}
+
+	eventData, err := NewEventData("/test", 0, customStruct, WithHeaders(headers), WithHeader("content-type", "application/json"))
+
+	if err != nil {
+		t.Fatalf("Error creating new event data: %v", err)
+	}
+
+	if len(eventData.Headers()) != 3 || eventData.Headers()["content-type"] != "application/json" {
+		t.Errorf("Expected 3 headers including content-type, got %v", eventData.Headers())
+	}
+
+	var jsonString string
+	var jsonBytes []byte
+
+	values := make(map[string]interface{})
+
+	for _, target := range []interface{}{&jsonString, &jsonBytes, &values} {
+		if err := eventData.NormalizeInto(target); err != nil {
+			t.Fatalf("Error normalizing data: %v", err)
+		}
+	}
+
+	// the entries of Redis Stream keep the headers in flat fields
+	if values[EventHeaderPrefix+"tenant"] != "acme" {
+		t.Errorf("Expected the field %stenant to be 'acme', got %v", EventHeaderPrefix, values)
+	}
+
+	if _, ok := values[EventHeadersKey]; ok {
+		t.Errorf("Expected no nested headers in the stream values, got %v", values)
+	}
+
+	for _, data := range []interface{}{jsonString, jsonBytes, values} {
+		parsed, err := ParseIncomingEventData(data)
+
+		if err != nil {
+			t.Fatalf("Error parsing incoming event data: %v", err)
+		}
+
+		if len(parsed.Headers()) != 3 || parsed.Headers()["correlation-id"] != "abc" || parsed.Headers()["tenant"] != "acme" {
+			t.Errorf("Expected the headers to round trip, got %v from %T", parsed.Headers(), data)
+		}
+
+		result := CustomStruct{}
+
+		if err := parsed.UnmarshalPayload(&result); err != nil {
+			t.Errorf("Error unmarshaling payload: %v", err)
+		}
+
+		if err := compareStruct(&result); err != nil {
+			t.Errorf("Error comparing received data: %v", err)
+		}
+	}
+}
+
+func TestEventData_WithoutHeaders(t *testing.T) {
+	eventData, err := NewEventData("/test", 0, customStruct)
+
+	if err != nil {
+		t.Fatalf("Error creating new event data: %v", err)
+	}
+
+	var jsonString string
+
+	if err := eventData.NormalizeInto(&jsonString); err != nil {
+		t.Fatalf("Error normalizing data: %v", err)
+	}
+
+	if strings.Contains(jsonString, EventHeadersKey) {
+		t.Errorf("Expected no headers in the envelope, got %s", jsonString)
+	}
+
+	// the envelopes published before headers were introduced
+	parsed, err := ParseIncomingEventData(`{"action":"/legacy","ttl":1,"timestamp":1630000000000,"payload":"{}"}`)
+
+	if err != nil {
+		t.Fatalf("Error parsing incoming event data: %v", err)
+	}
+
+	if len(parsed.Headers()) != 0 {
+		t.Errorf("Expected no headers, got %v", parsed.Headers())
+	}
+}
+
 func compareStruct(s *CustomStruct) error {
 	if s.Name != customStruct.Name {
 		return fmt.Errorf("expected name to be 'John', got '%s'", s.Name)
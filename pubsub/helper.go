@@ -1,8 +1,10 @@
 package pubsub
 
 import (
+	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func NormalizeActionPath(action string) string {
@@ -70,3 +72,26 @@ func ParseTimestamp(value interface{}) int64 {
 
 	return parsed
 }
+
+// Backoff returns the delay before the given attempt (starting from 1),
+// doubling from minBackoff up to maxBackoff, with a random jitter of up to half of the delay.
+// It paces the reconnection of the workers, and the retries of eventhandler.Retry.
+func Backoff(attempt int, minBackoff, maxBackoff time.Duration) time.Duration {
+	delay := minBackoff
+
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	half := int64(delay / 2)
+
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
@@ -19,6 +19,12 @@ const (
 	EventTTLKey       = "ttl"
 	EventTimestampKey = "timestamp"
 
+	// EventHeadersKey is the reserved envelope key of the headers in the JSON of Redis PubSub and Kafka.
+	EventHeadersKey = "headers"
+
+	// EventHeaderPrefix prefixes the field of every header in the entries of Redis Stream, e.g. "header:tenant".
+	EventHeaderPrefix = "header:"
+
 	MinimumTTL = 0
 )
 
@@ -27,29 +33,48 @@ var (
 	ErrNoSubscriberConsumed       = errors.New("message not consumed by any subscriber")
 	ErrUnsupportedEventPayload    = errors.New("unsupported event payload")
 	ErrUnsupportedNormalizeTarget = errors.New("unsupported normalize target")
+	ErrPubSubStopped              = errors.New("pubsub already stopped")
+	ErrPatternTopicNotSupported   = errors.New("pattern topic not supported")
 )
 
-// todo: enable unsubscribe
 type UnifiedPubSub interface {
 
 	// Publish publishes a message to a topic
 	// If message is map[string]interface{}, its value must be string, int, float64, bool, []byte, or nil.
+	// The trace context of ctx is injected into the headers of the event via the global propagator of OpenTelemetry.
 	Publish(ctx context.Context, event Event) error
 
 	// Subscribe subscribes to given topics.
 	// The receiver can get all incoming events from the Events() channel.
+	// ErrPubSubStopped is returned once the PubSub is stopped.
 	Subscribe(topics ...Topic) error
 
+	// Unsubscribe stops receiving events from the given topics.
+	// Other topics keep delivering to the Events() channel; topics that are not subscribed are ignored.
+	Unsubscribe(topics ...string) error
+
 	// Events returns a channel that receives all incoming events.
 	// As long as the internal worker is running, the channel will keep receiving events.
-	// The event channel will be closed when Stop() is called.
+	// The event channel will be closed when Stop() or Shutdown() is called.
 	Events() <-chan Event
 
-	// Errors returns a channel that receives all errors that occur during the subscription.
-	// It is typically designed for Kafka-based pub/sub.
+	// Errors returns a channel that receives all errors that occur during the subscription,
+	// e.g. ParseError, TransportError, PanicError, DisconnectedError for Redis, or kafka.Error for Kafka.
+	// It also receives ReconnectedStatus once a disconnected Redis worker recovers, which is not a failure;
+	// use errors.As to tell it apart from the errors.
+	// The channel is buffered and never blocks the workers: errors are dropped when it is full.
+	// The error channel will be closed when Stop() or Shutdown() is called.
 	Errors() <-chan error
 
 	Topics() []string
 
+	// Stop stops the workers, abandoning the events that are not yet received from the Events() channel.
+	// It is the same as Shutdown with a context that is already done, except that no error is returned for that.
 	Stop() (SyncPoint, error)
+
+	// Shutdown stops fetching new events, lets the in-flight events be received from the Events() channel
+	// until ctx is done, and waits for the workers to exit before capturing the SyncPoint.
+	// The SyncPoint covers exactly the events handed to the receiver, so restarting from it re-delivers the abandoned ones.
+	// ctx.Err() is returned along with the SyncPoint if any in-flight event is abandoned.
+	Shutdown(ctx context.Context) (SyncPoint, error)
 }
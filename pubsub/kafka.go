@@ -1,33 +1,568 @@
 package pubsub
 
-import "context"
+import (
+	"context"
+	"errors"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"log/slog"
+	"sync"
+	"time"
+)
 
-func WithKafka() UnifiedPubSub {
-	return &kafkaPubSub{}
+var (
+	ErrEmptyConsumerGroup = errors.New("empty consumer group id")
+)
+
+const (
+	kafkaPollTimeoutMs   = 100
+	kafkaCommitTimeoutMs = 5000
+	kafkaFlushTimeoutMs  = 5000
+)
+
+// WithKafka creates a new PubSub instance with Kafka.
+// The config is shared by the producer and the consumers, and must contain at least "bootstrap.servers".
+// Every distinct Topic.Offset() is used as the consumer group ID, so topics subscribed with the same offset
+// share one consumer; the consumer starts from the earliest offset unless "auto.offset.reset" is configured.
+// PatternTopic is not supported, and the options other than WithLogger are ignored.
+func WithKafka(config *kafka.ConfigMap, opts ...Option) (UnifiedPubSub, error) {
+
+	producer, err := kafka.NewProducer(cloneKafkaConfig(config))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &kafkaPubSub{
+		config:    config,
+		producer:  producer,
+		eventChan: make(chan Event),
+		errChan:   make(chan error, errorBufferSize),
+		topics:    make(map[string]Topic),
+		workers:   make(map[string]*kafkaWorkerImpl),
+		committed: make(map[string]map[int32]int64),
+		mu:        &sync.Mutex{},
+		done:      make(chan struct{}),
+		opts:      opts,
+	}
+
+	go ps.forwardProducerErrors()
+
+	return ps, nil
 }
 
-type kafkaPubSub struct{}
+type kafkaPubSub struct {
+	config *kafka.ConfigMap
+
+	producer *kafka.Producer
+
+	eventChan chan Event
+
+	errChan chan error
 
+	topics map[string]Topic
+
+	// workers are keyed by the consumer group ID
+	workers map[string]*kafkaWorkerImpl
+
+	// committed keeps the committed offsets of the stopped workers
+	committed map[string]map[int32]int64
+
+	mu *sync.Mutex
+
+	done chan struct{}
+
+	opts []Option
+
+	stopped bool
+}
+
+// Publish publishes a message to a topic, using EventID.EntryID as the message key.
+// It waits for the delivery report of the message, or returns when the context is done.
 func (ps *kafkaPubSub) Publish(ctx context.Context, event Event) error {
+
+	event, err := injectTraceContext(ctx, event)
+
+	if err != nil {
+		return err
+	}
+
+	value := make([]byte, 0)
+
+	err = event.NormalizeInto(&value)
+
+	if err != nil {
+		return err
+	}
+
+	id := event.ID()
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &id.Topic,
+			Partition: kafka.PartitionAny,
+		},
+		Value: value,
+	}
+
+	if id.EntryID != "" {
+		msg.Key = []byte(id.EntryID)
+	}
+
+	delivery := make(chan kafka.Event, 1)
+
+	ps.mu.Lock()
+
+	if ps.stopped {
+		ps.mu.Unlock()
+		return ErrPubSubStopped
+	}
+
+	err = ps.producer.Produce(msg, delivery)
+
+	ps.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-delivery:
+		switch e := e.(type) {
+		case *kafka.Message:
+			return e.TopicPartition.Error
+		case kafka.Error:
+			return e
+		}
+
+		return nil
+	}
+}
+
+// Subscribe subscribes to given topics, grouping them by their consumer group ID (Topic.Offset()).
+// As a Kafka subscription replaces the previous one, the consumer of a group would be re-created
+// with all topics of the group when new topics are added to it.
+// It returns ErrEmptyConsumerGroup if a topic has no consumer group ID, and the topics of a group
+// are only recorded once its consumer starts, so that they can be subscribed again after an error.
+func (ps *kafkaPubSub) Subscribe(topics ...Topic) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	for _, topic := range topics {
+		if isPatternTopic(topic) {
+			return ErrPatternTopicNotSupported
+		}
+
+		if topic.Offset() == "" {
+			return ErrEmptyConsumerGroup
+		}
+	}
+
+	added := make(map[string]Topic)
+
+	for _, topic := range topics {
+		name := topic.Name()
+
+		if _, ok := ps.topics[name]; !ok {
+			added[name] = topic
+		}
+	}
+
+	updatedGroups := make(map[string]bool)
+
+	for _, topic := range added {
+		updatedGroups[topic.Offset()] = true
+	}
+
+	for group := range updatedGroups {
+
+		subscribed := ps.groupTopics(group)
+
+		groupTopics := append([]Topic{}, subscribed...)
+
+		for _, topic := range added {
+			if topic.Offset() == group {
+				groupTopics = append(groupTopics, topic)
+			}
+		}
+
+		_ = ps.stopWorker(cancelledContext(), group)
+
+		err := ps.runWorker(group, groupTopics)
+
+		if err != nil {
+			// resume the topics subscribed before, which are dropped if their consumer cannot be re-created either
+			if len(subscribed) > 0 && ps.runWorker(group, subscribed) != nil {
+				for _, topic := range subscribed {
+					delete(ps.topics, topic.Name())
+				}
+			}
+
+			return err
+		}
+
+		for _, topic := range groupTopics {
+			ps.topics[topic.Name()] = topic
+		}
+	}
+
+	return nil
+}
+
+// Unsubscribe removes the given topics from their consumer groups.
+// The consumer of an affected group would be re-created with the remaining topics of the group, if any.
+func (ps *kafkaPubSub) Unsubscribe(topics ...string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	updatedGroups := make(map[string]bool)
+
+	for _, name := range topics {
+		topic, ok := ps.topics[name]
+
+		if !ok {
+			continue
+		}
+
+		delete(ps.topics, name)
+		updatedGroups[topic.Offset()] = true
+	}
+
+	for group := range updatedGroups {
+
+		_ = ps.stopWorker(cancelledContext(), group)
+
+		groupTopics := ps.groupTopics(group)
+
+		if len(groupTopics) == 0 {
+			continue
+		}
+
+		if err := ps.runWorker(group, groupTopics); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (ps *kafkaPubSub) Subscribe(...Topic) error {
+// groupTopics returns the subscribed topics of the given consumer group.
+func (ps *kafkaPubSub) groupTopics(group string) []Topic {
+	groupTopics := make([]Topic, 0)
+
+	for _, topic := range ps.topics {
+		if topic.Offset() == group {
+			groupTopics = append(groupTopics, topic)
+		}
+	}
+
+	return groupTopics
+}
+
+// runWorker starts the worker consuming the topics within the given consumer group.
+func (ps *kafkaPubSub) runWorker(group string, topics []Topic) error {
+	worker := NewKafkaWorker(ps.config, group, ps.errChan, ps.opts...)
+
+	if err := worker.Run(topics, ps.eventChan); err != nil {
+		return err
+	}
+
+	ps.workers[group] = worker.(*kafkaWorkerImpl)
+
 	return nil
 }
 
 func (ps *kafkaPubSub) Events() <-chan Event {
-	return nil
+	return ps.eventChan
 }
 
+// Errors returns a channel that receives errors reported by the brokers.
+// The channel is buffered, and errors are dropped when nobody reads it.
 func (ps *kafkaPubSub) Errors() <-chan error {
-	return nil
+	return ps.errChan
+}
+
+func (ps *kafkaPubSub) Topics() []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	topics := make([]string, 0)
+
+	for _, topic := range ps.topics {
+		topics = append(topics, topic.Name())
+	}
+
+	return topics
 }
 
+// Stop stops all consumers and the producer, abandoning the in-flight events.
 func (ps *kafkaPubSub) Stop() (SyncPoint, error) {
-	return SyncPoint{}, nil
+	point, _ := ps.Shutdown(cancelledContext())
+
+	return point, nil
 }
 
-func (ps *kafkaPubSub) Topics() []string {
+// Shutdown stops all consumers, committing the offsets of the events handed to the receiver, and the producer.
+// The returned SyncPoint maps every topic to its consumer group ID, so that SyncPoint.AsTopics resumes in the same group,
+// and SyncPoint.Committed carries the committed offset of each assigned partition.
+func (ps *kafkaPubSub) Shutdown(ctx context.Context) (SyncPoint, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return SyncPoint{}, nil
+	}
+
+	ps.stopped = true
+
+	var shutdownErr error
+
+	for group := range ps.workers {
+		if err := ps.stopWorker(ctx, group); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	point := SyncPoint{
+		Timestamp: time.Now().UnixMilli(),
+		Offsets:   make(map[string]string),
+		Committed: ps.committed,
+	}
+
+	for _, topic := range ps.topics {
+		point.Offsets[topic.Name()] = topic.Offset()
+	}
+
+	ps.producer.Flush(kafkaFlushTimeoutMs)
+	ps.producer.Close()
+
+	<-ps.done
+
+	close(ps.eventChan)
+	close(ps.errChan)
+
+	return point, shutdownErr
+}
+
+// stopWorker shuts down the worker of the given consumer group if any,
+// and keeps its committed offsets for the SyncPoint.
+func (ps *kafkaPubSub) stopWorker(ctx context.Context, group string) error {
+	worker, ok := ps.workers[group]
+
+	if !ok {
+		return nil
+	}
+
+	err := worker.Shutdown(ctx)
+	delete(ps.workers, group)
+
+	for _, tp := range worker.committed {
+		if tp.Topic == nil || tp.Offset < 0 {
+			continue
+		}
+
+		if _, ok := ps.committed[*tp.Topic]; !ok {
+			ps.committed[*tp.Topic] = make(map[int32]int64)
+		}
+
+		ps.committed[*tp.Topic][tp.Partition] = int64(tp.Offset)
+	}
+
+	return err
+}
+
+// forwardProducerErrors forwards the errors of the producer to the error channel.
+// Delivery reports are handled in Publish, so only the errors would reach the producer's event channel.
+func (ps *kafkaPubSub) forwardProducerErrors() {
+	defer close(ps.done)
+
+	for e := range ps.producer.Events() {
+		if err, ok := e.(kafka.Error); ok {
+			reportError(ps.errChan, err)
+		}
+	}
+}
+
+type kafkaWorkerImpl struct {
+	config kafka.ConfigMap
+
+	group string
+
+	errs chan<- error
+
+	logger *slog.Logger
+
+	*lifecycle
+
+	consumer *kafka.Consumer
+
+	committed []kafka.TopicPartition
+}
+
+// NewKafkaWorker creates a worker consuming topics within the given consumer group.
+// Errors reported by the brokers are sent to errs without blocking.
+// The options other than WithLogger are ignored.
+func NewKafkaWorker(config *kafka.ConfigMap, group string, errs chan<- error, opts ...Option) Worker {
+	consumerConfig := cloneKafkaConfig(config)
+
+	_ = consumerConfig.SetKey("group.id", group)
+
+	// offsets are stored manually once the event is handed to the receiver
+	_ = consumerConfig.SetKey("enable.auto.offset.store", false)
+
+	if _, ok := (*consumerConfig)["auto.offset.reset"]; !ok {
+		_ = consumerConfig.SetKey("auto.offset.reset", "earliest")
+	}
+
+	return &kafkaWorkerImpl{
+		config:    *consumerConfig,
+		group:     group,
+		errs:      errs,
+		logger:    newOptions(opts...).logger,
+		lifecycle: newLifecycle(),
+	}
+}
+
+func (w *kafkaWorkerImpl) Run(topics []Topic, receiver chan<- Event) error {
+
+	if w.consumer != nil {
+		return ErrWorkerAlreadyStarted
+	}
+
+	names := make([]string, 0)
+
+	for _, topic := range topics {
+		names = append(names, topic.Name())
+	}
+
+	consumer, err := kafka.NewConsumer(&w.config)
+
+	if err != nil {
+		return err
+	}
+
+	err = consumer.SubscribeTopics(names, nil)
+
+	if err != nil {
+		_ = consumer.Close()
+		return err
+	}
+
+	w.consumer = consumer
+
+	w.goroutine(func() {
+		for {
+			if w.stopping() {
+				return
+			}
+
+			switch e := consumer.Poll(kafkaPollTimeoutMs).(type) {
+			case *kafka.Message:
+				id := &EventID{
+					EntryID: string(e.Key),
+				}
+
+				if e.TopicPartition.Topic != nil {
+					id.Topic = *e.TopicPartition.Topic
+				}
+
+				event, err := NewIncomingEvent(id, e.Value)
+
+				if err != nil {
+					w.logger.Error("Error parsing incoming event payload",
+						LogKeyTopic, id.Topic, LogKeyEntryID, id.EntryID, LogKeyError, err)
+					reportError(w.errs, &ParseError{Topic: id.Topic, EntryID: id.EntryID, Err: err})
+					_, _ = consumer.StoreMessage(e)
+					continue
+				}
+
+				if !w.deliver(receiver, event) {
+					return
+				}
+
+				_, _ = consumer.StoreMessage(e)
+			case kafka.Error:
+				reportError(w.errs, e)
+			}
+		}
+	})
+
 	return nil
 }
+
+// Stop stops consuming, abandoning the in-flight event, then commits the stored offsets and closes the consumer.
+func (w *kafkaWorkerImpl) Stop() {
+	w.stop()
+	w.close()
+}
+
+// Shutdown stops polling, lets the in-flight event be delivered until ctx is done,
+// then commits the offsets of the delivered events and closes the consumer.
+func (w *kafkaWorkerImpl) Shutdown(ctx context.Context) error {
+	err := w.shutdown(ctx)
+
+	w.close()
+
+	return err
+}
+
+// close commits the stored offsets and closes the consumer, once the polling goroutine exits,
+// as the consumer cannot be closed while polling.
+func (w *kafkaWorkerImpl) close() {
+	if w.consumer == nil {
+		return
+	}
+
+	_, err := w.consumer.Commit()
+
+	if err != nil {
+		var kafkaErr kafka.Error
+
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrNoOffset {
+			reportError(w.errs, err)
+		}
+	}
+
+	assignment, err := w.consumer.Assignment()
+
+	if err == nil && len(assignment) > 0 {
+		committed, err := w.consumer.Committed(assignment, kafkaCommitTimeoutMs)
+
+		if err != nil {
+			reportError(w.errs, err)
+		} else {
+			w.committed = committed
+		}
+	}
+
+	err = w.consumer.Close()
+
+	if err != nil {
+		reportError(w.errs, err)
+	}
+
+	w.consumer = nil
+}
+
+func cloneKafkaConfig(config *kafka.ConfigMap) *kafka.ConfigMap {
+	cloned := kafka.ConfigMap{}
+
+	if config == nil {
+		return &cloned
+	}
+
+	for key, value := range *config {
+		cloned[key] = value
+	}
+
+	return &cloned
+}
@@ -0,0 +1,230 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"testing"
+	"time"
+)
+
+func newMockKafka(t *testing.T, topics ...string) *kafka.ConfigMap {
+	cluster, err := kafka.NewMockCluster(1)
+
+	if err != nil {
+		t.Fatalf("Error creating mock cluster: %v", err)
+	}
+
+	t.Cleanup(cluster.Close)
+
+	for _, topic := range topics {
+		err = cluster.CreateTopic(topic, 1, 1)
+
+		if err != nil {
+			t.Fatalf("Error creating topic: %v", err)
+		}
+	}
+
+	return &kafka.ConfigMap{
+		"bootstrap.servers":  cluster.BootstrapServers(),
+		"session.timeout.ms": 6000,
+	}
+}
+
+func TestKafkaPubSub(t *testing.T) {
+	config := newMockKafka(t, "kafka-test")
+
+	ps, err := WithKafka(config)
+
+	if err != nil {
+		t.Fatalf("Error creating kafka pubsub: %v", err)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic:   "kafka-test",
+		EntryID: "entry-key",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = ps.Publish(ctx, event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	err = ps.Subscribe(NewTopic("kafka-test", "kafka-test-group"))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.ID().Topic != "kafka-test" {
+			t.Errorf("Expected topic to be 'kafka-test', got '%s'", e.ID().Topic)
+		}
+
+		if e.ID().EntryID != "entry-key" {
+			t.Errorf("Expected entry ID to be 'entry-key', got '%s'", e.ID().EntryID)
+		}
+
+		if e.Action() != "/custom" {
+			t.Errorf("Expected action to be '/custom', got '%s'", e.Action())
+		}
+
+		s := &CustomStruct{}
+
+		err = e.UnmarshalPayload(s)
+
+		if err != nil {
+			t.Errorf("Error deserialize EventData: %v", err)
+		}
+
+		err = compareStruct(s)
+
+		if err != nil {
+			t.Errorf("Error comparing received data: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	point, err := ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping pubsub: %v", err)
+	}
+
+	if point.Offsets["kafka-test"] != "kafka-test-group" {
+		t.Errorf("Expected offset to be the consumer group, got '%s'", point.Offsets["kafka-test"])
+	}
+
+	if point.Committed["kafka-test"][0] != 1 {
+		t.Errorf("Expected committed offset to be 1, got %v", point.Committed["kafka-test"])
+	}
+
+	if _, ok := <-ps.Events(); ok {
+		t.Errorf("Expected event channel to be closed")
+	}
+}
+
+func TestKafkaPubSub_ResumeFromSyncPoint(t *testing.T) {
+	config := newMockKafka(t, "kafka-resume")
+
+	ps, err := WithKafka(config)
+
+	if err != nil {
+		t.Fatalf("Error creating kafka pubsub: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, action := range []string{"/first", "/second"} {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: "kafka-resume",
+		}, action, 0, nil)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = ps.Publish(ctx, event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	err = ps.Subscribe(NewTopic("kafka-resume", "kafka-resume-group"))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.Action() != "/first" {
+			t.Errorf("Expected action to be '/first', got '%s'", e.Action())
+		}
+	case <-ctx.Done():
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	point, err := ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping pubsub: %v", err)
+	}
+
+	resumed, err := WithKafka(config)
+
+	if err != nil {
+		t.Fatalf("Error creating kafka pubsub: %v", err)
+	}
+
+	err = resumed.Subscribe(point.AsTopics()...)
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case e := <-resumed.Events():
+		if e.Action() != "/second" {
+			t.Errorf("Expected action to be '/second', got '%s'", e.Action())
+		}
+	case <-ctx.Done():
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	_, _ = resumed.Stop()
+}
+
+func TestKafkaPubSub_SubscribeError(t *testing.T) {
+	config := newMockKafka(t, "kafka-subscribed", "kafka-failed")
+
+	ps, err := WithKafka(config)
+
+	if err != nil {
+		t.Fatalf("Error creating kafka pubsub: %v", err)
+	}
+
+	defer ps.Stop()
+
+	if err = ps.Subscribe(NewTopic("kafka-failed", "")); !errors.Is(err, ErrEmptyConsumerGroup) {
+		t.Errorf("Expected ErrEmptyConsumerGroup, got %v", err)
+	}
+
+	if err = ps.Subscribe(NewTopic("kafka-subscribed", "kafka-subscribed-group")); err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	// the consumers created from now on fail
+	(*config)["session.timeout.ms"] = "invalid"
+
+	if err = ps.Subscribe(NewTopic("kafka-failed", "kafka-failed-group")); err == nil {
+		t.Fatalf("Expected subscribing with an invalid config to fail")
+	}
+
+	if topics := ps.Topics(); len(topics) != 1 || topics[0] != "kafka-subscribed" {
+		t.Errorf("Expected only kafka-subscribed to be recorded, got %v", topics)
+	}
+
+	(*config)["session.timeout.ms"] = 6000
+
+	// the failed topic is not taken as subscribed
+	if err = ps.Subscribe(NewTopic("kafka-failed", "kafka-failed-group")); err != nil {
+		t.Fatalf("Error subscribing again: %v", err)
+	}
+
+	if topics := ps.Topics(); len(topics) != 2 {
+		t.Errorf("Expected both topics to be recorded, got %v", topics)
+	}
+}
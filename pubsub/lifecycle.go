@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle tracks the goroutines of a worker, so that it can be shut down gracefully:
+// ctx is cancelled to stop fetching, and abort is closed to abandon the in-flight deliveries.
+// An abandoned event is never marked as consumed (e.g. its offset is not synced),
+// so it would be fetched again after restarting from the SyncPoint.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	abort     chan struct{}
+	abortOnce *sync.Once
+
+	wg *sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &lifecycle{
+		ctx:       ctx,
+		cancel:    cancel,
+		abort:     make(chan struct{}),
+		abortOnce: &sync.Once{},
+		wg:        &sync.WaitGroup{},
+	}
+}
+
+// goroutine runs fn in a goroutine tracked by the lifecycle.
+func (l *lifecycle) goroutine(fn func()) {
+	l.wg.Add(1)
+
+	go func() {
+		defer l.wg.Done()
+		fn()
+	}()
+}
+
+// deliver sends the event to the receiver, and returns false if the delivery is abandoned.
+// Once fetching is stopped, the in-flight event can still be delivered until the lifecycle is aborted.
+func (l *lifecycle) deliver(receiver chan<- Event, event Event) bool {
+	select {
+	case receiver <- event:
+		return true
+	case <-l.abort:
+		return false
+	}
+}
+
+// stopping reports whether the worker should stop fetching.
+func (l *lifecycle) stopping() bool {
+	return l.ctx.Err() != nil
+}
+
+// shutdown stops fetching, and waits for the goroutines to exit.
+// If ctx is done before that, the in-flight deliveries are abandoned and ctx.Err() is returned
+// after the goroutines exit, which may take as long as a blocking read of the worker.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.abortOnce.Do(func() {
+		close(l.abort)
+	})
+
+	<-done
+
+	return ctx.Err()
+}
+
+// stop abandons the in-flight deliveries and waits for the goroutines to exit.
+func (l *lifecycle) stop() {
+	_ = l.shutdown(cancelledContext())
+}
+
+// cancelledContext returns a context that is already done, to shut down without waiting for the deliveries.
+func cancelledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	return ctx
+}
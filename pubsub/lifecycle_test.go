@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func addStreamEntries(t *testing.T, client *redis.Client, stream string, count int) []string {
+	ids := make([]string, 0)
+
+	for i := 0; i < count; i++ {
+		id, err := client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: stream,
+			Values: map[string]interface{}{
+				EventActionKey:    fmt.Sprintf("/custom-%d", i),
+				EventTimestampKey: time.Now().UnixMilli(),
+			},
+		}).Result()
+
+		if err != nil {
+			t.Fatalf("Error adding stream entry: %v", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestStreamPubSub_ShutdownAbandonsInFlight(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ids := addStreamEntries(t, client, "shutdown-stream", 3)
+
+	ps := WithStream(client, 0)
+
+	err := ps.Subscribe(NewTopic("shutdown-stream", string(MinimumID)))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.ID().EntryID != ids[0] {
+			t.Errorf("Expected entry %s, got %s", ids[0], e.ID().EntryID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	// the second entry is in flight, but nobody receives it
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	point, err := ps.Shutdown(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if point.Offsets["shutdown-stream"] != ids[0] {
+		t.Errorf("Expected offset to be the last delivered entry %s, got %s", ids[0], point.Offsets["shutdown-stream"])
+	}
+
+	if _, ok := <-ps.Events(); ok {
+		t.Errorf("Expected event channel to be closed")
+	}
+
+	if err = ps.Subscribe(NewTopic("another-stream", "")); !errors.Is(err, ErrPubSubStopped) {
+		t.Errorf("Expected ErrPubSubStopped, got %v", err)
+	}
+
+	// stopping again is a no-op
+	_, _ = ps.Stop()
+
+	resumed := WithStream(client, 0)
+
+	err = resumed.Subscribe(point.AsTopics()...)
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	for _, id := range ids[1:] {
+		select {
+		case e := <-resumed.Events():
+			if e.ID().EntryID != id {
+				t.Errorf("Expected entry %s, got %s", id, e.ID().EntryID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event %s", id)
+		}
+	}
+
+	_, _ = resumed.Stop()
+}
+
+func TestStreamPubSub_ShutdownDrainsInFlight(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ids := addStreamEntries(t, client, "drain-stream", 3)
+
+	ps := WithStream(client, 0)
+
+	err := ps.Subscribe(NewTopic("drain-stream", string(MinimumID)))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	// let the worker block on handing over the first entry
+	time.Sleep(100 * time.Millisecond)
+
+	type result struct {
+		point SyncPoint
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		point, err := ps.Shutdown(ctx)
+		done <- result{point, err}
+	}()
+
+	received := make([]string, 0)
+
+	for e := range ps.Events() {
+		received = append(received, e.ID().EntryID)
+	}
+
+	if len(received) == 0 || received[0] != ids[0] {
+		t.Fatalf("Expected the in-flight entry %s to be delivered, got %v", ids[0], received)
+	}
+
+	r := <-done
+
+	if r.err != nil {
+		t.Errorf("Error shutting down: %v", r.err)
+	}
+
+	// the entries fetched before the worker notices the shutdown may be delivered as well,
+	// but the offset always matches the last one received.
+	last := received[len(received)-1]
+
+	if r.point.Offsets["drain-stream"] != last {
+		t.Errorf("Expected offset to be the last delivered entry %s, got %s", last, r.point.Offsets["drain-stream"])
+	}
+}
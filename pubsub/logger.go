@@ -0,0 +1,47 @@
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+)
+
+// The keys of the structured fields logged by the PubSub and its workers.
+// The payloads are never logged, as they may carry user content.
+const (
+	LogKeyTopic   = "topic"
+	LogKeyEntryID = "entry_id"
+	LogKeyAction  = "action"
+	LogKeyError   = "error"
+)
+
+// discardLogger is the default logger, which logs nothing.
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (h discardHandler) Enabled(context.Context, slog.Level) bool {
+	return false
+}
+
+func (h discardHandler) Handle(context.Context, slog.Record) error {
+	return nil
+}
+
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h discardHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// WithLogger sets the logger of the PubSub and its workers, e.g. for the entries that cannot be parsed,
+// with the fields LogKeyTopic, LogKeyEntryID, LogKeyAction and LogKeyError where available.
+// Nothing is logged by default; a nil logger keeps the default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
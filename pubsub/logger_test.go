@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	malformedID, err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: "logged-stream",
+		Values: map[string]interface{}{
+			EventPayloadKey: "private chat content",
+		},
+	}).Result()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, nil))
+
+	ps := WithStream(client, 0, WithStreamRead(20*time.Millisecond, 0), WithLogger(logger))
+
+	err = ps.Subscribe(NewTopic("logged-stream", string(MinimumID)))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	// the error is reported after being logged
+	select {
+	case <-ps.Errors():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for the parse error")
+	}
+
+	_, _ = ps.Stop()
+
+	line, _, _ := strings.Cut(buffer.String(), "\n")
+
+	record := make(map[string]interface{})
+
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		t.Fatalf("Error decoding the log record %q: %v", line, err)
+	}
+
+	if record["level"] != "ERROR" || record[LogKeyTopic] != "logged-stream" || record[LogKeyEntryID] != malformedID {
+		t.Errorf("Expected an error of logged-stream/%s, got %v", malformedID, record)
+	}
+
+	if _, ok := record[LogKeyError]; !ok {
+		t.Errorf("Expected the error field, got %v", record)
+	}
+
+	if strings.Contains(buffer.String(), "private chat content") {
+		t.Errorf("Expected the payload not to be logged, got %s", buffer.String())
+	}
+}
+
+func TestDiscardLogger(t *testing.T) {
+	o := newOptions()
+
+	if o.logger.Enabled(context.Background(), slog.LevelError) {
+		t.Errorf("Expected nothing to be logged by default")
+	}
+
+	o = newOptions(WithLogger(nil))
+
+	if o.logger != discardLogger {
+		t.Errorf("Expected a nil logger to keep the default")
+	}
+}
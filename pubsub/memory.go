@@ -0,0 +1,489 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithMemory creates a new PubSub instance keeping the events in memory, with the semantics of Redis PubSub:
+// an event is only delivered to the topics subscribed when it is published, and the SyncPoint is always empty.
+// Publish returns ErrNoSubscriberConsumed if nobody subscribes to the topic.
+// PatternTopic is not supported.
+// Unless WithMemoryBroker is given, the instance has its own broker.
+func WithMemory(opts ...Option) UnifiedPubSub {
+	return newMemoryPubSub(false, 0, opts)
+}
+
+// WithMemoryStream creates a new PubSub instance keeping the events in memory, with the semantics of Redis Stream:
+// the events are retained, a topic is read from its offset (the last delivered ID), and the SyncPoint carries the offsets.
+// As WithStream, the events created before the lastSync are dropped, and Publish returns ErrStreamNotFound
+// unless the stream exists: create the streams via WithMemoryStreams, or MemoryBroker.CreateStream of
+// the broker given by WithMemoryBroker.
+// Consumer groups, dead letters and reclaiming are not supported, and their options are ignored.
+// PatternTopic is not supported either.
+// Unless WithMemoryBroker is given, the instance has its own broker.
+func WithMemoryStream(lastSync int64, opts ...Option) UnifiedPubSub {
+	return newMemoryPubSub(true, lastSync, opts)
+}
+
+func newMemoryPubSub(retained bool, lastSync int64, opts []Option) *memoryPubSub {
+	errChan := newErrorChannel()
+
+	o := newOptions(withErrorChannel(opts, errChan)...)
+
+	if o.broker == nil {
+		o.broker = NewMemoryBroker()
+	}
+
+	for _, stream := range o.streams {
+		o.broker.CreateStream(stream)
+	}
+
+	return &memoryPubSub{
+		broker:       o.broker,
+		retained:     retained,
+		eventChan:    make(chan Event),
+		errChan:      errChan,
+		topics:       make(map[string]Topic),
+		unsubscribed: make(map[string]Topic),
+		workers:      make(map[string]*memoryWorker),
+		mu:           &sync.Mutex{},
+		lastSync:     lastSync,
+		options:      o,
+	}
+}
+
+// As there is no connection to share, the in-memory PubSub runs a worker for each topic,
+// so that a topic can be subscribed or unsubscribed without affecting the others.
+type memoryPubSub struct {
+	broker *MemoryBroker
+
+	// retained switches between the semantics of Redis Stream and Redis PubSub
+	retained bool
+
+	eventChan chan Event
+
+	errChan *errorChannel
+
+	topics map[string]Topic
+
+	// unsubscribed keeps the unsubscribed topics, so that their last offsets are still included in the SyncPoint
+	unsubscribed map[string]Topic
+
+	workers map[string]*memoryWorker
+
+	mu *sync.Mutex
+
+	lastSync int64
+
+	options *options
+
+	stopped bool
+}
+
+func (ps *memoryPubSub) Publish(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	event, err := injectTraceContext(ctx, event)
+
+	if err != nil {
+		return err
+	}
+
+	id := event.ID()
+
+	if !ps.retained {
+		value := ""
+
+		err = event.NormalizeInto(&value)
+
+		if err != nil {
+			return err
+		}
+
+		if ps.broker.publish(id.Topic, value) == 0 {
+			return ErrNoSubscriberConsumed
+		}
+
+		return nil
+	}
+
+	jsonData := make(map[string]interface{})
+
+	err = event.NormalizeInto(&jsonData)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ps.broker.add(id.Topic, id.EntryID, stringifyValues(jsonData))
+
+	return err
+}
+
+func (ps *memoryPubSub) Subscribe(topics ...Topic) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	// as WithStream re-creates its worker, the entries added before subscribing more topics are dropped
+	resubscribed := ps.retained && len(ps.workers) > 0
+
+	for _, topic := range topics {
+		if isPatternTopic(topic) {
+			return ErrPatternTopicNotSupported
+		}
+	}
+
+	for _, topic := range topics {
+
+		name := topic.Name()
+
+		if _, ok := ps.topics[name]; ok {
+			continue
+		}
+
+		if resubscribed {
+			ps.lastSync = max(ps.lastSync, time.Now().UnixMilli())
+		}
+
+		worker := newMemoryWorker(ps.broker, ps.retained, ps.lastSync, ps.options)
+
+		err := worker.Run([]Topic{topic}, ps.eventChan)
+
+		if err != nil {
+			return err
+		}
+
+		ps.topics[name] = topic
+		ps.workers[name] = worker
+		delete(ps.unsubscribed, name)
+	}
+
+	return nil
+}
+
+func (ps *memoryPubSub) Unsubscribe(topics ...string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	for _, name := range topics {
+		topic, ok := ps.topics[name]
+
+		if !ok {
+			continue
+		}
+
+		ps.workers[name].Stop()
+
+		delete(ps.workers, name)
+		delete(ps.topics, name)
+		ps.unsubscribed[name] = topic
+	}
+
+	return nil
+}
+
+func (ps *memoryPubSub) Events() <-chan Event {
+	return ps.eventChan
+}
+
+// Errors returns a channel that receives the errors reported by the workers, e.g. ParseError.
+// The channel is buffered, errors are dropped when nobody reads it, and it is closed when Stop() is called.
+func (ps *memoryPubSub) Errors() <-chan error {
+	return ps.errChan.ch
+}
+
+func (ps *memoryPubSub) Topics() []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	topics := make([]string, 0)
+
+	for _, topic := range ps.topics {
+		topics = append(topics, topic.Name())
+	}
+
+	return topics
+}
+
+func (ps *memoryPubSub) Stop() (SyncPoint, error) {
+	point, _ := ps.Shutdown(cancelledContext())
+
+	return point, nil
+}
+
+// Shutdown stops the workers, and waits for them to deliver the in-flight events until ctx is done.
+// With the semantics of Redis Stream, the returned SyncPoint carries the last delivered ID of each topic.
+func (ps *memoryPubSub) Shutdown(ctx context.Context) (SyncPoint, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return SyncPoint{}, nil
+	}
+
+	ps.stopped = true
+
+	var shutdownErr error
+
+	for _, worker := range ps.workers {
+		if err := worker.Shutdown(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	ps.workers = make(map[string]*memoryWorker)
+	close(ps.eventChan)
+	ps.errChan.close()
+
+	if !ps.retained || len(ps.topics)+len(ps.unsubscribed) == 0 {
+		return SyncPoint{}, shutdownErr
+	}
+
+	point := SyncPoint{
+		Timestamp: max(ps.lastSync, time.Now().UnixMilli()),
+		Offsets:   make(map[string]string),
+	}
+
+	for _, topic := range ps.unsubscribed {
+		point.Offsets[topic.Name()] = topic.Offset()
+	}
+
+	for _, topic := range ps.topics {
+		point.Offsets[topic.Name()] = topic.Offset()
+	}
+
+	ps.unsubscribed = make(map[string]Topic)
+
+	return point, shutdownErr
+}
+
+// memoryWorker delivers the events of a MemoryBroker,
+// either from a subscription of channels, or by reading the streams from the offsets of the topics.
+type memoryWorker struct {
+	broker *MemoryBroker
+
+	*lifecycle
+
+	retained bool
+
+	topics []Topic
+
+	lastSync int64
+
+	options *options
+}
+
+func newMemoryWorker(broker *MemoryBroker, retained bool, lastSync int64, o *options) *memoryWorker {
+	return &memoryWorker{
+		broker:    broker,
+		lifecycle: newLifecycle(),
+		retained:  retained,
+		lastSync:  lastSync,
+		options:   o,
+	}
+}
+
+func (w *memoryWorker) Run(topics []Topic, receiver chan<- Event) error {
+
+	if len(w.topics) > 0 {
+		return ErrWorkerAlreadyStarted
+	}
+
+	w.topics = topics
+
+	if !w.retained {
+		names := make([]string, 0)
+
+		for _, topic := range topics {
+			names = append(names, topic.Name())
+		}
+
+		// subscribe before returning, so that the events published right after Subscribe are delivered
+		sub := w.broker.subscribe(names)
+
+		w.goroutine(func() {
+			defer w.broker.unsubscribe(sub)
+			w.receive(sub, receiver)
+		})
+
+		return nil
+	}
+
+	for _, topic := range topics {
+		offset := topic.Offset()
+
+		if offset == string(MaximumID) {
+			topic.SyncOffset(w.broker.lastEntryID(topic.Name()))
+		} else if offset != "" {
+			if _, err := parseStreamID(offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, topic := range topics {
+		topic := topic
+
+		w.goroutine(func() {
+			w.read(topic, receiver)
+		})
+	}
+
+	return nil
+}
+
+// receive delivers the messages of the subscription to the receiver until the worker is stopped.
+func (w *memoryWorker) receive(sub *memorySubscription, receiver chan<- Event) {
+	defer recoverWorker(w.options)
+
+	for {
+		messages, notify := w.broker.receive(sub)
+
+		if len(messages) == 0 {
+			select {
+			case <-notify:
+				continue
+			case <-w.ctx.Done():
+				return
+			}
+		}
+
+		for _, m := range messages {
+			event, err := NewIncomingEvent(&EventID{
+				Topic: m.channel,
+			}, m.payload)
+
+			if err != nil {
+				w.options.logger.Error("Error parsing incoming event payload",
+					LogKeyTopic, m.channel, LogKeyError, err)
+				w.options.reportError(&ParseError{Topic: m.channel, Err: err})
+				continue
+			}
+
+			if !w.deliver(receiver, event) || w.stopping() {
+				return
+			}
+		}
+	}
+}
+
+// read delivers the entries of the stream after the topic's offset, and syncs the offset after each delivery.
+func (w *memoryWorker) read(topic Topic, receiver chan<- Event) {
+	defer recoverWorker(w.options)
+
+	for {
+		offset := topic.Offset()
+
+		if offset == "" {
+			offset = string(MinimumID)
+		}
+
+		after, err := parseStreamID(offset)
+
+		if err != nil {
+			w.options.reportError(err)
+			return
+		}
+
+		entries, updated := w.broker.read(topic.Name(), after, w.options.count)
+
+		if len(entries) == 0 {
+			select {
+			case <-updated:
+				continue
+			case <-w.ctx.Done():
+				return
+			}
+		}
+
+		for _, entry := range entries {
+			if !w.consume(topic.Name(), entry, receiver) {
+				return
+			}
+
+			topic.SyncOffset(entry.id.String())
+
+			if w.stopping() {
+				return
+			}
+		}
+	}
+}
+
+// consume hands the entry to the receiver, and returns false if the delivery is abandoned.
+// The entries that cannot be parsed, or created before the lastSync, are dropped.
+func (w *memoryWorker) consume(stream string, entry memoryEntry, receiver chan<- Event) bool {
+	event, err := NewIncomingEvent(&EventID{
+		Topic:   stream,
+		EntryID: entry.id.String(),
+	}, entry.values)
+
+	if err != nil {
+		w.options.logger.Error("Error parsing incoming event payload",
+			LogKeyTopic, stream, LogKeyEntryID, entry.id.String(), LogKeyError, err)
+		w.options.reportError(&ParseError{Topic: stream, EntryID: entry.id.String(), Err: err})
+		return true
+	}
+
+	if event.Timestamp() <= w.lastSync {
+		return true
+	}
+
+	return w.deliver(receiver, event)
+}
+
+// Stop stops the worker, abandoning the in-flight events, and waits for it to exit.
+func (w *memoryWorker) Stop() {
+	w.stop()
+}
+
+// Shutdown stops the worker, and waits for the in-flight events to be delivered until ctx is done.
+func (w *memoryWorker) Shutdown(ctx context.Context) error {
+	return w.shutdown(ctx)
+}
+
+// stringifyValues converts the values of a stream entry into strings, as Redis returns them.
+func stringifyValues(values map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(values))
+
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			converted[key] = v
+		case []byte:
+			converted[key] = string(v)
+		case int:
+			converted[key] = strconv.Itoa(v)
+		case int64:
+			converted[key] = strconv.FormatInt(v, 10)
+		case float64:
+			converted[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			if v {
+				converted[key] = "1"
+			} else {
+				converted[key] = "0"
+			}
+		case nil:
+			converted[key] = ""
+		default:
+			converted[key] = fmt.Sprint(v)
+		}
+	}
+
+	return converted
+}
@@ -0,0 +1,271 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidStreamID    = errors.New("invalid stream id")
+	ErrStreamIDNotGreater = errors.New("stream id is equal or smaller than the last entry")
+	ErrStreamNotFound     = errors.New("stream not found")
+)
+
+// MemoryBroker keeps the channels and streams of the in-memory PubSub, like a Redis server in the process.
+// Instances created by WithMemory or WithMemoryStream with the same broker (see WithMemoryBroker)
+// see the events published by each other, e.g. to resume from the SyncPoint of a stopped instance.
+type MemoryBroker struct {
+	mu *sync.Mutex
+
+	// streams keeps all the entries added to each stream
+	streams map[string]*memoryStream
+
+	// channels keeps the subscriptions of each channel
+	channels map[string]map[*memorySubscription]bool
+
+	// updated is closed and replaced whenever an entry is added to any stream
+	updated chan struct{}
+}
+
+// NewMemoryBroker creates an empty broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		mu:       &sync.Mutex{},
+		streams:  make(map[string]*memoryStream),
+		channels: make(map[string]map[*memorySubscription]bool),
+		updated:  make(chan struct{}),
+	}
+}
+
+type memoryStream struct {
+	entries []memoryEntry
+
+	lastID streamID
+}
+
+type memoryEntry struct {
+	id streamID
+
+	values map[string]interface{}
+}
+
+// memorySubscription buffers the messages published to its channels until the worker receives them,
+// as Redis does for a subscribed connection.
+type memorySubscription struct {
+	messages []memoryMessage
+
+	// notify is signaled when messages are buffered
+	notify chan struct{}
+}
+
+type memoryMessage struct {
+	channel string
+
+	payload string
+}
+
+// publish buffers the payload into the subscriptions of the channel, and returns the number of them.
+func (b *MemoryBroker) publish(channel, payload string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.channels[channel] {
+		sub.messages = append(sub.messages, memoryMessage{channel: channel, payload: payload})
+
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return len(b.channels[channel])
+}
+
+func (b *MemoryBroker) subscribe(channels []string) *memorySubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &memorySubscription{
+		notify: make(chan struct{}, 1),
+	}
+
+	for _, channel := range channels {
+		if _, ok := b.channels[channel]; !ok {
+			b.channels[channel] = make(map[*memorySubscription]bool)
+		}
+
+		b.channels[channel][sub] = true
+	}
+
+	return sub
+}
+
+func (b *MemoryBroker) unsubscribe(sub *memorySubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for channel, subs := range b.channels {
+		delete(subs, sub)
+
+		if len(subs) == 0 {
+			delete(b.channels, channel)
+		}
+	}
+}
+
+// receive takes the buffered messages of the subscription.
+// If there is none, the returned channel is signaled once a message is published.
+func (b *MemoryBroker) receive(sub *memorySubscription) ([]memoryMessage, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages := sub.messages
+	sub.messages = nil
+
+	return messages, sub.notify
+}
+
+// CreateStream creates the stream if it does not exist, as XGROUP CREATE with MKSTREAM does for Redis,
+// since WithMemoryStream publishes only to the existing streams (see WithStream).
+func (b *MemoryBroker) CreateStream(stream string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.streams[stream]; !ok {
+		b.streams[stream] = &memoryStream{}
+	}
+}
+
+// add appends an entry to the stream, and returns ErrStreamNotFound if the stream does not exist, as XADD with NOMKSTREAM.
+// As XADD, the id may be AutoGeneratedID, or an explicit ID greater than the last entry of the stream.
+func (b *MemoryBroker) add(stream, id string, values map[string]interface{}) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[stream]
+
+	if !ok {
+		return "", ErrStreamNotFound
+	}
+
+	var entryID streamID
+
+	if id == "" || id == string(AutoGeneratedID) {
+		entryID = s.lastID.next(time.Now().UnixMilli())
+	} else {
+		parsed, err := parseStreamID(id)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !s.lastID.less(parsed) {
+			return "", ErrStreamIDNotGreater
+		}
+
+		entryID = parsed
+	}
+
+	s.entries = append(s.entries, memoryEntry{id: entryID, values: values})
+	s.lastID = entryID
+
+	close(b.updated)
+	b.updated = make(chan struct{})
+
+	return entryID.String(), nil
+}
+
+// lastEntryID returns the ID of the last entry of the stream, i.e. what MaximumID ($) stands for.
+func (b *MemoryBroker) lastEntryID(stream string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[stream]
+
+	if !ok {
+		return string(MinimumID)
+	}
+
+	return s.lastID.String()
+}
+
+// read returns at most count entries after the given ID of the stream.
+// If there is none, the returned channel is closed once an entry is added to any stream.
+func (b *MemoryBroker) read(stream string, after streamID, count int64) ([]memoryEntry, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[stream]
+
+	if !ok {
+		return nil, b.updated
+	}
+
+	entries := make([]memoryEntry, 0)
+
+	for _, entry := range s.entries {
+		if !after.less(entry.id) {
+			continue
+		}
+
+		entries = append(entries, entry)
+
+		if count > 0 && int64(len(entries)) >= count {
+			break
+		}
+	}
+
+	return entries, b.updated
+}
+
+// streamID is the <milliseconds>-<sequence> ID of a stream entry, as Redis Stream uses.
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+// parseStreamID parses a complete ID, or a bare milliseconds part which stands for <milliseconds>-0.
+func parseStreamID(id string) (streamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(id, "-")
+
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+
+	if err != nil {
+		return streamID{}, fmt.Errorf("%w: %s", ErrInvalidStreamID, id)
+	}
+
+	if !hasSeq {
+		return streamID{ms: ms}, nil
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+
+	if err != nil {
+		return streamID{}, fmt.Errorf("%w: %s", ErrInvalidStreamID, id)
+	}
+
+	return streamID{ms: ms, seq: seq}, nil
+}
+
+func (id streamID) less(other streamID) bool {
+	return id.ms < other.ms || (id.ms == other.ms && id.seq < other.seq)
+}
+
+// next generates the ID following id, using the given time unless it is behind the last ID.
+func (id streamID) next(now int64) streamID {
+	ms := uint64(max(now, 0))
+
+	if ms <= id.ms {
+		return streamID{ms: id.ms, seq: id.seq + 1}
+	}
+
+	return streamID{ms: ms}
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
@@ -0,0 +1,244 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func publishActions(t *testing.T, ps UnifiedPubSub, topic string, actions ...string) {
+	for _, action := range actions {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: topic,
+		}, action, 0, customStruct)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = ps.Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+}
+
+func receiveActions(t *testing.T, ps UnifiedPubSub, actions ...string) []Event {
+	events := make([]Event, 0)
+
+	for _, action := range actions {
+		select {
+		case e := <-ps.Events():
+			if e.Action() != action {
+				t.Errorf("Expected action to be '%s', got '%s'", action, e.Action())
+			}
+
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event %s", action)
+		}
+	}
+
+	return events
+}
+
+func TestMemoryPubSub(t *testing.T) {
+	ps := WithMemory()
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "memory-test",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed, got %v", err)
+	}
+
+	err = ps.Subscribe(NewTopic("memory-test", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	publishActions(t, ps, "memory-test", "/first", "/second")
+
+	for _, e := range receiveActions(t, ps, "/first", "/second") {
+		s := &CustomStruct{}
+
+		err = e.UnmarshalPayload(s)
+
+		if err != nil {
+			t.Errorf("Error deserialize EventData: %v", err)
+		}
+
+		err = compareStruct(s)
+
+		if err != nil {
+			t.Errorf("Error comparing received data: %v", err)
+		}
+	}
+
+	err = ps.Unsubscribe("memory-test")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed after unsubscribing, got %v", err)
+	}
+
+	point, err := ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping pubsub: %v", err)
+	}
+
+	if len(point.Offsets) != 0 {
+		t.Errorf("Expected empty SyncPoint, got %v", point)
+	}
+
+	if _, ok := <-ps.Events(); ok {
+		t.Errorf("Expected event channel to be closed")
+	}
+}
+
+func TestMemoryStream_ResumeFromSyncPoint(t *testing.T) {
+	broker := NewMemoryBroker()
+	broker.CreateStream("memory-stream")
+
+	ps := WithMemoryStream(0, WithMemoryBroker(broker))
+
+	publishActions(t, ps, "memory-stream", "/first", "/second")
+
+	err := ps.Subscribe(NewTopic("memory-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	events := receiveActions(t, ps, "/first", "/second")
+
+	publishActions(t, ps, "memory-stream", "/third")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the third event may be in flight, but nobody receives it
+	point, err := ps.Shutdown(ctx)
+
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Error shutting down: %v", err)
+	}
+
+	if point.Offsets["memory-stream"] != events[1].ID().EntryID {
+		t.Errorf("Expected offset to be %s, got %s", events[1].ID().EntryID, point.Offsets["memory-stream"])
+	}
+
+	resumed := WithMemoryStream(0, WithMemoryBroker(broker))
+
+	err = resumed.Subscribe(point.AsTopics()...)
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	receiveActions(t, resumed, "/third")
+
+	_, _ = resumed.Stop()
+}
+
+func TestMemoryStream_LastSync(t *testing.T) {
+	broker := NewMemoryBroker()
+	broker.CreateStream("memory-last-sync")
+
+	_, err := broker.add("memory-last-sync", "", map[string]interface{}{
+		EventActionKey:    "/old",
+		EventTimestampKey: "1000",
+	})
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	ps := WithMemoryStream(2000, WithMemoryBroker(broker))
+
+	err = ps.Subscribe(NewTopic("memory-last-sync", string(MinimumID)))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	publishActions(t, ps, "memory-last-sync", "/new")
+
+	receiveActions(t, ps, "/new")
+
+	_, _ = ps.Stop()
+}
+
+func TestMemoryStream_EntryID(t *testing.T) {
+	// the instance has its own broker, where the stream is created
+	ps := WithMemoryStream(0, WithMemoryStreams("memory-entry-id"), WithStreamRead(0, 1))
+
+	for i, id := range []string{"5-1", "5-2", "6"} {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic:   "memory-entry-id",
+			EntryID: id,
+		}, fmt.Sprintf("/custom-%d", i), 0, nil)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = ps.Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic:   "memory-entry-id",
+		EntryID: "5-3",
+	}, "/custom", 0, nil)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrStreamIDNotGreater) {
+		t.Errorf("Expected ErrStreamIDNotGreater, got %v", err)
+	}
+
+	err = ps.Subscribe(NewTopic("memory-entry-id", "5-1"))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	events := receiveActions(t, ps, "/custom-1", "/custom-2")
+
+	if events[1].ID().EntryID != "6-0" {
+		t.Errorf("Expected entry ID to be 6-0, got %s", events[1].ID().EntryID)
+	}
+
+	err = ps.Subscribe(NewTopic("memory-invalid-id", "invalid"))
+
+	if !errors.Is(err, ErrInvalidStreamID) {
+		t.Errorf("Expected ErrInvalidStreamID, got %v", err)
+	}
+
+	_, _ = ps.Stop()
+}
@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// DefaultStreamLagInterval is how often the Redis Stream worker samples the lag of its streams for Metrics.
+const DefaultStreamLagInterval = 15 * time.Second
+
+// Metrics receives the measurements of the publish and consume paths of the Redis backends, see WithMetrics.
+// The methods are called from the publishing and consuming goroutines, so they must be safe for concurrent use and must not block.
+type Metrics interface {
+	// EventPublished records an event published to the topic.
+	EventPublished(topic, action string)
+
+	// PublishFailed records an event that failed to be published, e.g. with ErrNoSubscriberConsumed.
+	PublishFailed(topic, action string, err error)
+
+	// EventConsumed records an event delivered through Events().
+	EventConsumed(topic, action string)
+
+	// EventLatency records the end-to-end latency of a consumed event, from EventData.Timestamp() to its delivery.
+	// It is not called for the events without a timestamp.
+	EventLatency(topic, action string, latency time.Duration)
+
+	// DeliveryWaited records how long the worker waited for the receiver of Events() to take an event.
+	DeliveryWaited(topic string, wait time.Duration)
+
+	// ParseFailed records an entry of the topic that cannot be parsed as Event, see ParseError.
+	ParseFailed(topic string)
+
+	// StreamLag records how far the worker is behind the last entry of the stream,
+	// i.e. the time between the entry IDs of Topic.Offset() and the last generated entry reported by XINFO STREAM.
+	StreamLag(topic string, lag time.Duration)
+}
+
+// WithMetrics makes Redis PubSub and Redis Stream record their measurements into m.
+// The lag of the streams is sampled every DefaultStreamLagInterval, see WithStreamLagInterval.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithStreamLagInterval sets how often the Redis Stream worker samples the lag of its streams for WithMetrics.
+// The default is DefaultStreamLagInterval.
+func WithStreamLagInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.lagInterval = interval
+		}
+	}
+}
+
+// recordPublish records the result of publishing the event if the metrics are enabled.
+func (o *options) recordPublish(event Event, err error) {
+	if o.metrics == nil {
+		return
+	}
+
+	topic := event.ID().Topic
+
+	if err != nil {
+		o.metrics.PublishFailed(topic, event.Action(), err)
+		return
+	}
+
+	o.metrics.EventPublished(topic, event.Action())
+}
+
+func (o *options) recordParseFailure(topic string) {
+	if o.metrics != nil {
+		o.metrics.ParseFailed(topic)
+	}
+}
+
+// deliverMeasured delivers the event as lifecycle.deliver does, recording the wait and the latency of the delivery.
+func (o *options) deliverMeasured(l *lifecycle, receiver chan<- Event, event Event) bool {
+	if o.metrics == nil {
+		return l.deliver(receiver, event)
+	}
+
+	start := time.Now()
+
+	if !l.deliver(receiver, event) {
+		return false
+	}
+
+	delivered := time.Now()
+	id := event.ID()
+
+	o.metrics.DeliveryWaited(id.Topic, delivered.Sub(start))
+	o.metrics.EventConsumed(id.Topic, event.Action())
+
+	if event.Timestamp() > 0 {
+		o.metrics.EventLatency(id.Topic, event.Action(), delivered.Sub(time.UnixMilli(event.Timestamp())))
+	}
+
+	return true
+}
+
+// sampleLag records the lag of the streams every lagInterval, until the worker is stopped.
+func (w *streamWorkerImpl) sampleLag() {
+	ticker := time.NewTicker(w.options.lagInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, topic := range w.topics {
+			info, err := w.client.XInfoStream(w.ctx, topic.Name()).Result()
+
+			if err != nil {
+				w.options.logger.Debug("Error sampling the stream lag", LogKeyTopic, topic.Name(), LogKeyError, err)
+				continue
+			}
+
+			if lag, ok := streamLag(info, w.offset(topic.Name())); ok {
+				w.options.metrics.StreamLag(topic.Name(), lag)
+			}
+		}
+	}
+}
+
+// streamLag returns the time between the entry IDs of the offset and the last generated entry of the stream.
+// The offsets that are not entry IDs (e.g. MaximumID) are not sampled; MinimumID lags behind the first entry.
+func streamLag(info *redis.XInfoStream, offset string) (time.Duration, bool) {
+	last, err := parseStreamID(info.LastGeneratedID)
+
+	if err != nil {
+		return 0, false
+	}
+
+	if offset == "" || offset == string(MinimumID) {
+		if info.FirstEntry.ID == "" {
+			return 0, true
+		}
+
+		// nothing is consumed yet, so the worker is behind the whole stream
+		first, err := parseStreamID(info.FirstEntry.ID)
+
+		if err != nil {
+			return 0, false
+		}
+
+		return time.Duration(last.ms-first.ms) * time.Millisecond, true
+	}
+
+	current, err := parseStreamID(offset)
+
+	if err != nil {
+		return 0, false
+	}
+
+	if !current.less(last) {
+		return 0, true
+	}
+
+	return time.Duration(last.ms-current.ms) * time.Millisecond, true
+}
@@ -0,0 +1,226 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics counts the calls of every method by topic.
+type recordingMetrics struct {
+	mu *sync.Mutex
+
+	published     map[string]int
+	publishErrors map[string][]error
+	consumed      map[string]int
+	latencies     map[string]int
+	waits         map[string]int
+	parseFailures map[string]int
+	lags          map[string]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		mu:            &sync.Mutex{},
+		published:     make(map[string]int),
+		publishErrors: make(map[string][]error),
+		consumed:      make(map[string]int),
+		latencies:     make(map[string]int),
+		waits:         make(map[string]int),
+		parseFailures: make(map[string]int),
+		lags:          make(map[string]int),
+	}
+}
+
+func (m *recordingMetrics) EventPublished(topic, action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published[topic]++
+}
+
+func (m *recordingMetrics) PublishFailed(topic, action string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishErrors[topic] = append(m.publishErrors[topic], err)
+}
+
+func (m *recordingMetrics) EventConsumed(topic, action string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consumed[topic]++
+}
+
+func (m *recordingMetrics) EventLatency(topic, action string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies[topic]++
+}
+
+func (m *recordingMetrics) DeliveryWaited(topic string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits[topic]++
+}
+
+func (m *recordingMetrics) ParseFailed(topic string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parseFailures[topic]++
+}
+
+func (m *recordingMetrics) StreamLag(topic string, lag time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lags[topic]++
+}
+
+func TestRedisPubSub_Metrics(t *testing.T) {
+	client := newMiniRedis(t)
+	metrics := newRecordingMetrics()
+
+	ps := New(client, WithMetrics(metrics))
+
+	event, _ := NewOutgoingEvent(&EventID{Topic: "unsubscribed-topic"}, "/lost", 0, nil)
+
+	if err := ps.Publish(context.Background(), event); !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed, got %v", err)
+	}
+
+	err := ps.Subscribe(NewTopic("metrics-topic", ""))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	err = client.Publish(context.Background(), "metrics-topic", "malformed").Err()
+
+	if err != nil {
+		t.Fatalf("Error publishing malformed message: %v", err)
+	}
+
+	publishActions(t, ps, "metrics-topic", "/first", "/second")
+	receiveActions(t, ps, "/first", "/second")
+
+	_, _ = ps.Stop()
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	if metrics.published["metrics-topic"] != 2 || metrics.consumed["metrics-topic"] != 2 {
+		t.Errorf("Expected 2 published and consumed events, got %v and %v", metrics.published, metrics.consumed)
+	}
+
+	if metrics.latencies["metrics-topic"] != 2 || metrics.waits["metrics-topic"] != 2 {
+		t.Errorf("Expected the latency and the wait of 2 events, got %v and %v", metrics.latencies, metrics.waits)
+	}
+
+	if len(metrics.publishErrors["unsubscribed-topic"]) != 1 || !errors.Is(metrics.publishErrors["unsubscribed-topic"][0], ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed to be recorded, got %v", metrics.publishErrors)
+	}
+
+	if metrics.parseFailures["metrics-topic"] != 1 {
+		t.Errorf("Expected 1 parse failure, got %v", metrics.parseFailures)
+	}
+}
+
+// lastGeneratedIDHook completes the reply of XINFO STREAM with the last generated ID, which miniredis does not report.
+type lastGeneratedIDHook struct {
+	client *redis.Client
+}
+
+func (h *lastGeneratedIDHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *lastGeneratedIDHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+
+		info, ok := cmd.(*redis.XInfoStreamCmd)
+
+		if err != nil || !ok {
+			return err
+		}
+
+		last, err := h.client.XRevRangeN(ctx, cmd.Args()[2].(string), "+", "-", 1).Result()
+
+		if err == nil && len(last) > 0 {
+			info.Val().LastGeneratedID = last[0].ID
+		}
+
+		return nil
+	}
+}
+
+func (h *lastGeneratedIDHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestStreamPubSub_StreamLag(t *testing.T) {
+	client := newMiniRedis(t)
+	client.AddHook(&lastGeneratedIDHook{client: client})
+
+	metrics := newRecordingMetrics()
+
+	// the worker reads the entries at once, and syncs their offsets one by one as they are received
+	for i := 0; i < 20; i++ {
+		addStreamEntry(t, client, "lag-stream", "/sampled")
+	}
+
+	ps := WithStream(client, 0, WithMetrics(metrics), WithStreamLagInterval(time.Millisecond))
+
+	err := ps.Subscribe(NewTopic("lag-stream", string(MinimumID)))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	// the lag is sampled while the worker syncs the offsets
+	for i := 0; i < 20; i++ {
+		receiveActions(t, ps, "/sampled")
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	_, _ = ps.Stop()
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	if metrics.lags["lag-stream"] == 0 {
+		t.Errorf("Expected the lag of lag-stream to be sampled, got %v", metrics.lags)
+	}
+}
+
+func TestStreamLag(t *testing.T) {
+	info := &redis.XInfoStream{
+		LastGeneratedID: "5000-1",
+		FirstEntry:      redis.XMessage{ID: "1000-0"},
+	}
+
+	cases := map[string]struct {
+		lag time.Duration
+		ok  bool
+	}{
+		"3000-0":          {2 * time.Second, true},
+		"5000-1":          {0, true},
+		"6000-0":          {0, true},
+		string(MinimumID): {4 * time.Second, true},
+		string(MaximumID): {0, false},
+	}
+
+	for offset, expected := range cases {
+		lag, ok := streamLag(info, offset)
+
+		if lag != expected.lag || ok != expected.ok {
+			t.Errorf("Expected the lag of %s to be %v (%v), got %v (%v)", offset, expected.lag, expected.ok, lag, ok)
+		}
+	}
+
+	// an empty stream has no lag
+	if lag, ok := streamLag(&redis.XInfoStream{LastGeneratedID: "0-0"}, string(MinimumID)); lag != 0 || !ok {
+		t.Errorf("Expected no lag of an empty stream, got %v (%v)", lag, ok)
+	}
+}
@@ -0,0 +1,230 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Option configures a UnifiedPubSub and its workers.
+// Options that do not apply to a backend are ignored, e.g. consumer groups for Redis PubSub.
+type Option func(*options)
+
+const (
+	DefaultStreamBlock = time.Second
+	DefaultStreamCount = 100
+
+	// errorBufferSize is the capacity of the Errors() channel of the backends
+	errorBufferSize = 64
+)
+
+type options struct {
+	// block and count are the BLOCK and COUNT arguments of XREAD/XREADGROUP
+	block time.Duration
+	count int64
+
+	// group and consumer enable the consumer group mode of Redis Stream
+	group    string
+	consumer string
+
+	// reclaimIdle and reclaimInterval enable the reclaimer of pending entries in the consumer group mode
+	reclaimIdle     time.Duration
+	reclaimInterval time.Duration
+
+	// deadLetterSuffix enables the dead-letter stream (<topic><suffix>) of the workers
+	deadLetterSuffix string
+	maxDeliveries    int64
+
+	// minBackoff and maxBackoff pace the reconnection of the workers
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	// report receives the errors of the workers, and must not block them
+	report func(error)
+
+	// broker keeps the events of the in-memory PubSub, and streams are created in it beforehand
+	broker  *MemoryBroker
+	streams []string
+
+	// sharded makes Redis PubSub use SSUBSCRIBE/SPUBLISH
+	sharded bool
+
+	// discoveryInterval paces the discovery of the stream keys matching a PatternTopic
+	discoveryInterval time.Duration
+
+	// logger logs what cannot be returned to the caller, e.g. the entries that cannot be parsed
+	logger *slog.Logger
+
+	// metrics records the measurements of the Redis backends, and lagInterval paces the sampling of the stream lag
+	metrics     Metrics
+	lagInterval time.Duration
+}
+
+// withErrorChannel returns the options followed by the one reporting errors into errs,
+// besides the reporter given by WithErrors if any, without modifying the given slice.
+func withErrorChannel(opts []Option, errs *errorChannel) []Option {
+	merged := make([]Option, 0, len(opts)+1)
+	merged = append(merged, opts...)
+
+	return append(merged, func(o *options) {
+		given := o.report
+
+		o.report = func(err error) {
+			errs.report(err)
+
+			if given != nil {
+				given(err)
+			}
+		}
+	})
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		block:      DefaultStreamBlock,
+		count:      DefaultStreamCount,
+		minBackoff: DefaultReconnectMinBackoff,
+		maxBackoff: DefaultReconnectMaxBackoff,
+
+		discoveryInterval: DefaultPatternDiscoveryInterval,
+
+		logger: discardLogger,
+
+		lagInterval: DefaultStreamLagInterval,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithConsumerGroup makes the Redis Stream worker read as the given consumer of a consumer group (XREADGROUP),
+// so that the entries of a stream are load-balanced among the consumers of the group.
+// The group is created from the topic's offset if it does not exist; an empty consumer defaults to "<hostname>-<pid>".
+// Every delivered event implements AckableEvent and must be acknowledged once processed.
+func WithConsumerGroup(group, consumer string) Option {
+	return func(o *options) {
+		o.group = group
+		o.consumer = consumer
+
+		if o.consumer == "" {
+			hostname, _ := os.Hostname()
+			o.consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		}
+	}
+}
+
+// WithPendingReclaim makes the Redis Stream worker in the consumer group mode claim the entries
+// pending longer than minIdle in other consumers (e.g. a crashed one) every interval via XAUTOCLAIM,
+// and re-deliver them through the Events() channel with their delivery counts.
+// It is ignored without WithConsumerGroup.
+func WithPendingReclaim(minIdle, interval time.Duration) Option {
+	return func(o *options) {
+		o.reclaimIdle = minIdle
+		o.reclaimInterval = interval
+	}
+}
+
+// WithDeadLetter makes the workers write the entries that cannot be parsed as Event into a dead-letter stream
+// named <topic><suffix> (DefaultDeadLetterSuffix if empty) instead of dropping them.
+// In the consumer group mode, the reclaimed entries delivered more than maxDeliveries times are dead-lettered as well;
+// maxDeliveries <= 0 means no limit.
+func WithDeadLetter(suffix string, maxDeliveries int64) Option {
+	return func(o *options) {
+		o.deadLetterSuffix = suffix
+		o.maxDeliveries = maxDeliveries
+
+		if o.deadLetterSuffix == "" {
+			o.deadLetterSuffix = DefaultDeadLetterSuffix
+		}
+	}
+}
+
+// WithStreamRead sets how long the Redis Stream worker blocks on an idle stream, and how many entries it reads at most
+// from each stream at once. Non-positive values fall back to DefaultStreamBlock and DefaultStreamCount.
+func WithStreamRead(block time.Duration, count int64) Option {
+	return func(o *options) {
+		if block > 0 {
+			o.block = block
+		}
+
+		if count > 0 {
+			o.count = count
+		}
+	}
+}
+
+// WithReconnectBackoff sets the exponential backoff between the reconnection attempts of the workers,
+// doubling from minBackoff up to maxBackoff with jitter. Non-positive values fall back to the defaults.
+func WithReconnectBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		if minBackoff > 0 {
+			o.minBackoff = minBackoff
+		}
+
+		if maxBackoff > 0 {
+			o.maxBackoff = maxBackoff
+		}
+
+		if o.maxBackoff < o.minBackoff {
+			o.maxBackoff = o.minBackoff
+		}
+	}
+}
+
+// WithErrors makes the workers send their errors to errs, e.g. ParseError, DisconnectedError and PanicError.
+// The errors are dropped when errs is full, so that the workers would never be blocked.
+// With New, WithStream, WithMemory and WithMemoryStream, errs receives the errors besides their Errors() channel.
+func WithErrors(errs chan<- error) Option {
+	return func(o *options) {
+		o.report = func(err error) {
+			reportError(errs, err)
+		}
+	}
+}
+
+// reportError reports the error of a worker if anyone is listening.
+func (o *options) reportError(err error) {
+	if o.report != nil {
+		o.report(err)
+	}
+}
+
+// WithMemoryBroker makes the in-memory PubSub use the given broker,
+// so that the instances sharing it deliver the events published by each other.
+func WithMemoryBroker(b *MemoryBroker) Option {
+	return func(o *options) {
+		o.broker = b
+	}
+}
+
+// WithMemoryStreams creates the streams in the broker of the in-memory PubSub when the instance is created,
+// as WithMemoryStream publishes only to the existing streams; see MemoryBroker.CreateStream.
+func WithMemoryStreams(streams ...string) Option {
+	return func(o *options) {
+		o.streams = append(o.streams, streams...)
+	}
+}
+
+// WithShardedPubSub makes Redis PubSub use the sharded channels of Redis 7 (SSUBSCRIBE/SPUBLISH),
+// so that a message of a Redis Cluster is only propagated within the shard owning the channel's slot.
+// With a cluster client, the channels are subscribed by a worker per slot; use hash tags to share one.
+// Sharded and non-sharded channels are separated, so the publishers and subscribers must use the same mode.
+func WithShardedPubSub() Option {
+	return func(o *options) {
+		o.sharded = true
+	}
+}
+
+// WithPatternDiscovery sets how often Redis Stream discovers the stream keys matching the subscribed PatternTopic.
+// Non-positive values fall back to DefaultPatternDiscoveryInterval.
+func WithPatternDiscovery(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.discoveryInterval = interval
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pubsub
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPatternDiscoveryInterval = 5 * time.Second
+
+	// scanBatchSize is the COUNT hint of SCAN when discovering the stream keys
+	scanBatchSize = 100
+)
+
+// matchedTopic is a stream key discovered by a PatternTopic, whose events carry the pattern.
+type matchedTopic struct {
+	Topic
+
+	pattern string
+}
+
+// scanStreams returns the stream keys matching the pattern.
+// A Redis Cluster is scanned on every master, as SCAN only iterates the keys of one node.
+func scanStreams(ctx context.Context, c redis.UniversalClient, pattern string) ([]string, error) {
+	mu := &sync.Mutex{}
+	found := make(map[string]bool)
+
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.ScanType(ctx, 0, pattern, scanBatchSize, "stream").Iterator()
+
+		for iter.Next(ctx) {
+			mu.Lock()
+			found[iter.Val()] = true
+			mu.Unlock()
+		}
+
+		return iter.Err()
+	}
+
+	var err error
+
+	if cluster, ok := c.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(found))
+
+	for key := range found {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// discoverTopics returns the topics of the stream keys matching the patterns, which are not subscribed yet
+// nor unsubscribed by their names. The discovered streams start from the offset of their pattern.
+func (ps *pubSubStreamImpl) discoverTopics(ctx context.Context, patterns []Topic) ([]Topic, error) {
+	topics := make([]Topic, 0)
+
+	for _, pattern := range patterns {
+		keys, err := scanStreams(ctx, ps.client, pattern.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if _, ok := ps.topics[key]; ok || ps.excluded[key] {
+				continue
+			}
+
+			topics = append(topics, &matchedTopic{
+				Topic:   NewTopic(key, pattern.Offset()),
+				pattern: pattern.Name(),
+			})
+		}
+	}
+
+	return topics, nil
+}
+
+// runDiscovery periodically discovers the stream keys matching the subscribed patterns,
+// and restarts the worker with the new keys, until the PubSub is stopped.
+func (ps *pubSubStreamImpl) runDiscovery() {
+	ticker := time.NewTicker(ps.options.discoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ps.discovery.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := ps.discover()
+
+		if err != nil && !ps.discovery.stopping() {
+			ps.options.logger.Error("Error discovering stream keys", LogKeyError, err)
+			ps.options.reportError(&TransportError{Op: "SCAN", Topics: ps.patternNames(), Err: err})
+		}
+	}
+}
+
+func (ps *pubSubStreamImpl) discover() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped || len(ps.patterns) == 0 {
+		return nil
+	}
+
+	patterns := make([]Topic, 0)
+
+	for _, pattern := range ps.patterns {
+		patterns = append(patterns, pattern)
+	}
+
+	discovered, err := ps.discoverTopics(ps.discovery.ctx, patterns)
+
+	if err != nil {
+		return err
+	}
+
+	updatedTopics := ps.updateTopics(discovered)
+
+	if updatedTopics == nil {
+		return nil
+	}
+
+	// unlike Subscribe, the entries added to the discovered streams before now are delivered
+	return ps.restartWorker(updatedTopics)
+}
+
+func (ps *pubSubStreamImpl) patternNames() []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	names := make([]string, 0)
+
+	for name := range ps.patterns {
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+func addStreamEntry(t *testing.T, client *redis.Client, stream string, action string) {
+	err := client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{
+			EventActionKey:    action,
+			EventTimestampKey: time.Now().UnixMilli(),
+		},
+	}).Err()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+}
+
+func TestRedisPubSub_PatternTopic(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := New(client)
+
+	err := ps.Subscribe(NewPatternTopic("room:*", ""), NewTopic("lobby", ""))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	publishActions(t, ps, "room:1", "/joined")
+
+	e := receiveActions(t, ps, "/joined")[0]
+
+	if e.ID().Topic != "room:1" || e.ID().Pattern != "room:*" {
+		t.Errorf("Expected event of room:1 matched by room:*, got %s matched by '%s'", e.ID().Topic, e.ID().Pattern)
+	}
+
+	publishActions(t, ps, "lobby", "/entered")
+
+	e = receiveActions(t, ps, "/entered")[0]
+
+	if e.ID().Topic != "lobby" || e.ID().Pattern != "" {
+		t.Errorf("Expected event of lobby without a pattern, got %s matched by '%s'", e.ID().Topic, e.ID().Pattern)
+	}
+
+	err = ps.Unsubscribe("room:*")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		if n, err := client.PubSubNumPat(context.Background()).Result(); err == nil && n == 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "room:2",
+	}, "/joined", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed, got %v", err)
+	}
+
+	_, _ = ps.Stop()
+}
+
+func TestStreamPubSub_PatternTopic(t *testing.T) {
+	client := newMiniRedis(t)
+
+	addStreamEntry(t, client, "room:1", "/existing")
+
+	// a hash matching the pattern is not a stream, so it is not read
+	client.HSet(context.Background(), "room:hash", "field", "value")
+
+	ps := WithStream(client, 0, WithStreamRead(20*time.Millisecond, 0), WithPatternDiscovery(20*time.Millisecond))
+
+	err := ps.Subscribe(NewPatternTopic("room:*", string(MinimumID)))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	e := receiveActions(t, ps, "/existing")[0]
+
+	if e.ID().Topic != "room:1" || e.ID().Pattern != "room:*" {
+		t.Errorf("Expected event of room:1 matched by room:*, got %s matched by '%s'", e.ID().Topic, e.ID().Pattern)
+	}
+
+	// the stream created after subscribing is read once discovered
+	addStreamEntry(t, client, "room:2", "/discovered")
+
+	e = receiveActions(t, ps, "/discovered")[0]
+
+	if e.ID().Topic != "room:2" || e.ID().Pattern != "room:*" {
+		t.Errorf("Expected event of room:2 matched by room:*, got %s matched by '%s'", e.ID().Topic, e.ID().Pattern)
+	}
+
+	topics := ps.Topics()
+
+	if len(topics) != 3 {
+		t.Errorf("Expected the pattern and 2 discovered streams, got %v", topics)
+	}
+
+	err = ps.Unsubscribe("room:*")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	if topics := ps.Topics(); len(topics) != 0 {
+		t.Errorf("Expected no topics after unsubscribing the pattern, got %v", topics)
+	}
+
+	sp, err := ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping: %v", err)
+	}
+
+	// the discovered streams are kept in the SyncPoint, as the unsubscribed streams are
+	for _, stream := range []string{"room:1", "room:2"} {
+		if _, ok := sp.Offsets[stream]; !ok {
+			t.Errorf("Expected the offset of %s in the SyncPoint, got %v", stream, sp.Offsets)
+		}
+	}
+}
+
+func TestStreamPubSub_UnsubscribeDiscovered(t *testing.T) {
+	client := newMiniRedis(t)
+
+	addStreamEntry(t, client, "room:1", "/existing")
+	addStreamEntry(t, client, "room:2", "/existing")
+
+	ps := WithStream(client, 0, WithStreamRead(20*time.Millisecond, 0), WithPatternDiscovery(20*time.Millisecond))
+
+	err := ps.Subscribe(NewPatternTopic("room:*", string(MinimumID)))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	receiveActions(t, ps, "/existing", "/existing")
+
+	err = ps.Unsubscribe("room:1")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	// the unsubscribed key is not discovered again while its pattern is subscribed
+	addStreamEntry(t, client, "room:1", "/skipped")
+	time.Sleep(100 * time.Millisecond)
+
+	if topics := ps.Topics(); len(topics) != 2 {
+		t.Errorf("Expected the pattern and room:2, got %v", topics)
+	}
+
+	addStreamEntry(t, client, "room:2", "/kept")
+
+	if e := receiveActions(t, ps, "/kept")[0]; e.ID().Topic != "room:2" {
+		t.Errorf("Expected event of room:2, got %s", e.ID().Topic)
+	}
+
+	// until it is subscribed again
+	err = ps.Subscribe(NewTopic("room:1", string(MinimumID)))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	if topics := ps.Topics(); len(topics) != 3 {
+		t.Errorf("Expected the pattern, room:1 and room:2, got %v", topics)
+	}
+
+	_, _ = ps.Stop()
+}
+
+func TestPatternTopic_NotSupported(t *testing.T) {
+	ps := WithMemory()
+
+	err := ps.Subscribe(NewPatternTopic("room:*", ""))
+
+	if !errors.Is(err, ErrPatternTopicNotSupported) {
+		t.Errorf("Expected ErrPatternTopicNotSupported, got %v", err)
+	}
+
+	_, _ = ps.Stop()
+
+	worker := NewStreamWorker(newMiniRedis(t), 0)
+
+	err = worker.Run([]Topic{NewPatternTopic("room:*", "")}, make(chan Event))
+
+	if !errors.Is(err, ErrPatternTopicNotSupported) {
+		t.Errorf("Expected ErrPatternTopicNotSupported, got %v", err)
+	}
+}
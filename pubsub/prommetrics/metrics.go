@@ -0,0 +1,160 @@
+// Package prommetrics implements pubsub.Metrics and eventhandler.Metrics with Prometheus.
+package prommetrics
+
+import (
+	"context"
+	"errors"
+	"github.com/edgejumps/sportstalk-pubsub/eventhandler"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// The values of the "type" label of the publish errors, and the "result" label of the handled events.
+const (
+	ErrorTypeNoSubscriber = "no_subscriber"
+	ErrorTypeStopped      = "stopped"
+	ErrorTypeCanceled     = "canceled"
+	ErrorTypeDeadline     = "deadline_exceeded"
+	ErrorTypeUnmatched    = "unmatched"
+	ErrorTypeOther        = "other"
+
+	ResultOK = "ok"
+)
+
+var (
+	_ pubsub.Metrics       = (*Metrics)(nil)
+	_ eventhandler.Metrics = (*Metrics)(nil)
+)
+
+// Metrics records the measurements of the PubSub and the Registry as Prometheus metrics,
+// labelled by topic and action where available.
+type Metrics struct {
+	published     *prometheus.CounterVec
+	publishErrors *prometheus.CounterVec
+	consumed      *prometheus.CounterVec
+	parseFailures *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	deliveryWait  *prometheus.HistogramVec
+	streamLag     *prometheus.GaugeVec
+	handled       *prometheus.HistogramVec
+}
+
+// New creates the metrics prefixed with the namespace (if not empty), and registers them with reg.
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_published_total",
+			Help:      "Number of events published.",
+		}, []string{"topic", "action"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_errors_total",
+			Help:      "Number of events failed to be published, by error type.",
+		}, []string{"topic", "action", "type"}),
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_consumed_total",
+			Help:      "Number of events delivered through Events().",
+		}, []string{"topic", "action"}),
+		parseFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_failures_total",
+			Help:      "Number of entries that cannot be parsed as events.",
+		}, []string{"topic"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "event_latency_seconds",
+			Help:      "End-to-end latency from the event timestamp to its delivery.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"topic", "action"}),
+		deliveryWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "delivery_wait_seconds",
+			Help:      "Time the workers waited for the receiver of Events() to take an event.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"topic"}),
+		streamLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stream_lag_seconds",
+			Help:      "Time between the current offset and the last generated entry of a stream.",
+		}, []string{"topic"}),
+		handled: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Duration of routing the events to their handlers, by result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"topic", "action", "result"}),
+	}
+
+	collectors := []prometheus.Collector{
+		m.published, m.publishErrors, m.consumed, m.parseFailures,
+		m.latency, m.deliveryWait, m.streamLag, m.handled,
+	}
+
+	for _, collector := range collectors {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) EventPublished(topic, action string) {
+	m.published.WithLabelValues(topic, action).Inc()
+}
+
+func (m *Metrics) PublishFailed(topic, action string, err error) {
+	m.publishErrors.WithLabelValues(topic, action, ErrorType(err)).Inc()
+}
+
+func (m *Metrics) EventConsumed(topic, action string) {
+	m.consumed.WithLabelValues(topic, action).Inc()
+}
+
+func (m *Metrics) EventLatency(topic, action string, latency time.Duration) {
+	m.latency.WithLabelValues(topic, action).Observe(latency.Seconds())
+}
+
+func (m *Metrics) DeliveryWaited(topic string, wait time.Duration) {
+	m.deliveryWait.WithLabelValues(topic).Observe(wait.Seconds())
+}
+
+func (m *Metrics) ParseFailed(topic string) {
+	m.parseFailures.WithLabelValues(topic).Inc()
+}
+
+func (m *Metrics) StreamLag(topic string, lag time.Duration) {
+	m.streamLag.WithLabelValues(topic).Set(lag.Seconds())
+}
+
+func (m *Metrics) EventHandled(topic, action string, duration time.Duration, err error) {
+	result := ResultOK
+
+	if err != nil {
+		result = ErrorType(err)
+	}
+
+	m.handled.WithLabelValues(topic, action, result).Observe(duration.Seconds())
+}
+
+// ErrorType classifies the error into the value of the "type" (or "result") label,
+// so that the label has a bounded set of values.
+func ErrorType(err error) string {
+	switch {
+	case errors.Is(err, pubsub.ErrNoSubscriberConsumed):
+		return ErrorTypeNoSubscriber
+	case errors.Is(err, pubsub.ErrPubSubStopped):
+		return ErrorTypeStopped
+	case errors.Is(err, eventhandler.ErrHandlerNotFound):
+		return ErrorTypeUnmatched
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeDeadline
+	default:
+		return ErrorTypeOther
+	}
+}
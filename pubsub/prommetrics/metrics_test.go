@@ -0,0 +1,115 @@
+package prommetrics
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/edgejumps/sportstalk-pubsub/eventhandler"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+type noopHandler struct {
+	action string
+}
+
+func (h *noopHandler) Action() string {
+	return h.action
+}
+
+func (h *noopHandler) Handle(event pubsub.Event) error {
+	return nil
+}
+
+func TestMetrics(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	reg := prometheus.NewRegistry()
+
+	metrics, err := New(reg, "test")
+
+	if err != nil {
+		t.Fatalf("Error creating metrics: %v", err)
+	}
+
+	ps := pubsub.New(client, pubsub.WithMetrics(metrics))
+	registry := eventhandler.NewRegistry(eventhandler.WithMetrics(metrics))
+
+	_ = registry.Register(&noopHandler{action: "/created"})
+
+	err = ps.Subscribe(pubsub.NewTopic("prom-topic", ""))
+
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	for _, topic := range []string{"prom-topic", "nobody-topic"} {
+		event, _ := pubsub.NewOutgoingEvent(&pubsub.EventID{Topic: topic}, "/created", 0, nil)
+
+		_ = ps.Publish(context.Background(), event)
+	}
+
+	select {
+	case event := <-ps.Events():
+		if err := registry.Route(event); err != nil {
+			t.Errorf("Error routing event: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	_, _ = ps.Stop()
+
+	if v := testutil.ToFloat64(metrics.published.WithLabelValues("prom-topic", "/created")); v != 1 {
+		t.Errorf("Expected 1 published event, got %v", v)
+	}
+
+	if v := testutil.ToFloat64(metrics.publishErrors.WithLabelValues("nobody-topic", "/created", ErrorTypeNoSubscriber)); v != 1 {
+		t.Errorf("Expected 1 publish error without subscribers, got %v", v)
+	}
+
+	if v := testutil.ToFloat64(metrics.consumed.WithLabelValues("prom-topic", "/created")); v != 1 {
+		t.Errorf("Expected 1 consumed event, got %v", v)
+	}
+
+	// the histograms are collected once per label set
+	for name, expected := range map[string]int{
+		"test_event_latency_seconds":    1,
+		"test_delivery_wait_seconds":    1,
+		"test_handler_duration_seconds": 1,
+	} {
+		if n, err := testutil.GatherAndCount(reg, name); err != nil || n != expected {
+			t.Errorf("Expected %d series of %s, got %d (%v)", expected, name, n, err)
+		}
+	}
+
+	if _, err := New(reg, "test"); err == nil {
+		t.Errorf("Expected registering the metrics twice to fail")
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	cases := map[string]error{
+		ErrorTypeNoSubscriber: pubsub.ErrNoSubscriberConsumed,
+		ErrorTypeStopped:      pubsub.ErrPubSubStopped,
+		ErrorTypeUnmatched:    eventhandler.ErrHandlerNotFound,
+		ErrorTypeCanceled:     context.Canceled,
+		ErrorTypeDeadline:     context.DeadlineExceeded,
+		ErrorTypeOther:        errors.New("connection refused"),
+	}
+
+	for expected, err := range cases {
+		if got := ErrorType(err); got != expected {
+			t.Errorf("Expected the type of %v to be %s, got %s", err, expected, got)
+		}
+	}
+}
@@ -6,13 +6,22 @@ import (
 	"sync"
 )
 
-func New(c *redis.Client) UnifiedPubSub {
+// New creates a new PubSub instance with Redis PubSub.
+// Options such as WithDeadLetter are passed to the workers of the subscribed channels.
+// The client may be any redis.UniversalClient, e.g. a Redis Cluster client, optionally with WithShardedPubSub.
+func New(c redis.UniversalClient, opts ...Option) UnifiedPubSub {
+	errChan := newErrorChannel()
+
 	return &pubSubImpl{
 		client:    c,
 		eventChan: make(chan Event),
+		errChan:   errChan,
 		topics:    make(map[string]Topic),
 		workers:   make([]Worker, 0),
+		owners:    make(map[string]*workerImpl),
 		mu:        &sync.Mutex{},
+		opts:      withErrorChannel(opts, errChan),
+		options:   newOptions(opts...),
 	}
 }
 
@@ -20,28 +29,56 @@ func New(c *redis.Client) UnifiedPubSub {
 // so we would create a new worker for newly added topics when calling Subscribe,
 // instead of using the same worker for all topics.
 type pubSubImpl struct {
-	client *redis.Client
+	client redis.UniversalClient
 
 	eventChan chan Event
 
+	errChan *errorChannel
+
 	topics map[string]Topic
 
 	workers []Worker
 
+	// owners maps each subscribed channel to the worker subscribing it
+	owners map[string]*workerImpl
+
 	mu *sync.Mutex
+
+	opts []Option
+
+	options *options
+
+	stopped bool
 }
 
-func (ps *pubSubImpl) Publish(context context.Context, event Event) error {
+func (ps *pubSubImpl) Publish(context context.Context, event Event) (err error) {
+
+	// the original event is recorded, as the traced one is only built for the wire
+	defer func(event Event) {
+		ps.options.recordPublish(event, err)
+	}(event)
+
+	event, err = injectTraceContext(context, event)
+
+	if err != nil {
+		return err
+	}
 
 	value := ""
 
-	err := event.NormalizeInto(&value)
+	err = event.NormalizeInto(&value)
 
 	if err != nil {
 		return err
 	}
 
-	count, err := ps.client.Publish(context, event.ID().Topic, value).Result()
+	publish := ps.client.Publish
+
+	if ps.options.sharded {
+		publish = ps.client.SPublish
+	}
+
+	count, err := publish(context, event.ID().Topic, value).Result()
 
 	if err != nil {
 		return err
@@ -58,6 +95,10 @@ func (ps *pubSubImpl) Subscribe(topics ...Topic) error {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
 	newTopics := make([]Topic, 0)
 
 	for _, topic := range topics {
@@ -75,18 +116,90 @@ func (ps *pubSubImpl) Subscribe(topics ...Topic) error {
 		return nil
 	}
 
-	worker := NewWorker(ps.client)
-	ps.workers = append(ps.workers, worker)
+	groups := [][]Topic{newTopics}
+
+	// a sharded subscription of a Redis Cluster is bound to the node of one slot
+	if ps.options.sharded {
+		groups = groupTopicsBySlot(ps.client, newTopics)
+	}
+
+	for _, group := range groups {
+		worker := newWorker(ps.client, ps.opts...)
+		ps.workers = append(ps.workers, worker)
 
-	return worker.Run(newTopics, ps.eventChan)
+		for _, topic := range group {
+			ps.owners[topic.Name()] = worker
+		}
+
+		err := worker.Run(group, ps.eventChan)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Unsubscribe unsubscribes the channels from the workers owning them.
+// A worker would be stopped once it owns no channel.
+func (ps *pubSubImpl) Unsubscribe(topics ...string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	channels := make(map[*workerImpl][]string)
+
+	for _, name := range topics {
+		worker, ok := ps.owners[name]
+
+		if !ok {
+			continue
+		}
+
+		channels[worker] = append(channels[worker], name)
+	}
+
+	for worker, names := range channels {
+		remaining, err := worker.unsubscribe(names...)
+
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			delete(ps.owners, name)
+			delete(ps.topics, name)
+		}
+
+		if remaining > 0 {
+			continue
+		}
+
+		worker.Stop()
+
+		for i, w := range ps.workers {
+			if w == Worker(worker) {
+				ps.workers = append(ps.workers[:i], ps.workers[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
 }
 
 func (ps *pubSubImpl) Events() <-chan Event {
 	return ps.eventChan
 }
 
+// Errors returns a channel that receives the errors reported by the workers, e.g. DisconnectedError.
+// The channel is buffered, errors are dropped when nobody reads it, and it is closed when Stop() is called.
 func (ps *pubSubImpl) Errors() <-chan error {
-	return nil
+	return ps.errChan.ch
 }
 
 func (ps *pubSubImpl) Topics() []string {
@@ -103,21 +216,35 @@ func (ps *pubSubImpl) Topics() []string {
 }
 
 func (ps *pubSubImpl) Stop() (SyncPoint, error) {
+	point, _ := ps.Shutdown(cancelledContext())
+
+	return point, nil
+}
+
+// Shutdown unsubscribes all channels, and waits for the workers to deliver the received messages until ctx is done.
+// As Redis PUBSUB would not be able to persistent messages, the returned SyncPoint is always empty.
+func (ps *pubSubImpl) Shutdown(ctx context.Context) (SyncPoint, error) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	if len(ps.workers) == 0 {
+	if ps.stopped {
 		return SyncPoint{}, nil
 	}
 
-	defer func() {
-		ps.workers = make([]Worker, 0)
-		close(ps.eventChan)
-	}()
+	ps.stopped = true
+
+	var shutdownErr error
 
 	for _, worker := range ps.workers {
-		worker.Stop()
+		if err := worker.Shutdown(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
 	}
 
-	return SyncPoint{}, nil
+	ps.workers = make([]Worker, 0)
+	ps.owners = make(map[string]*workerImpl)
+	close(ps.eventChan)
+	ps.errChan.close()
+
+	return SyncPoint{}, shutdownErr
 }
@@ -2,7 +2,9 @@ package pubsub
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"math/rand"
 	"sync"
@@ -12,6 +14,9 @@ import (
 
 var rdb *redis.Client
 
+// rdbErr is why the local Redis is not reachable, if so.
+var rdbErr error
+
 func init() {
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     "127.0.0.1:6379",
@@ -19,14 +24,33 @@ func init() {
 		//ContextTimeoutEnabled: true,
 	})
 
-	err := rdb.Ping(context.Background()).Err()
+	rdbErr = rdb.Ping(context.Background()).Err()
+}
 
-	if err != nil {
-		panic(err)
+// requireRedis skips the test if the local Redis is not reachable.
+func requireRedis(t *testing.T) {
+	if rdbErr != nil {
+		t.Skipf("redis is not reachable: %v", rdbErr)
 	}
 }
 
+func newMiniRedis(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
 func TestRedisStream(t *testing.T) {
+	requireRedis(t)
+
 	ps := WithStream(rdb, 0)
 
 	action := fmt.Sprintf("/custom-struct-%d", rand.Intn(100))
@@ -77,6 +101,8 @@ func TestRedisStream(t *testing.T) {
 }
 
 func TestRedisPubSub(t *testing.T) {
+	requireRedis(t)
+
 	ps := New(rdb)
 
 	action := fmt.Sprintf("/custom-struct-%d", rand.Intn(100))
@@ -134,6 +160,8 @@ func TestRedisPubSub(t *testing.T) {
 }
 
 func TestStreamPubSub_MultipleTopics(t *testing.T) {
+	requireRedis(t)
+
 	ps := WithStream(rdb, 0)
 
 	action := fmt.Sprintf("/custom-struct-%d", rand.Intn(100))
@@ -166,7 +194,7 @@ func TestStreamPubSub_MultipleTopics(t *testing.T) {
 
 	for e := range ps.Events() {
 
-		fmt.Printf("Received event: %v\n", e.ID().Topic)
+		t.Logf("Received event: %v", e.ID().Topic)
 
 		if e.Action() == action {
 
@@ -190,3 +218,502 @@ func TestStreamPubSub_MultipleTopics(t *testing.T) {
 
 	ps.Stop()
 }
+
+func TestRedisPubSub_Unsubscribe(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := New(client)
+
+	err := ps.Subscribe(NewTopic("unsubscribe-a", ""), NewTopic("unsubscribe-b", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	err = ps.Unsubscribe("unsubscribe-a")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	waitNoSubscriber(t, client, "unsubscribe-a")
+
+	topics := ps.Topics()
+
+	if len(topics) != 1 || topics[0] != "unsubscribe-b" {
+		t.Errorf("Expected topics to be [unsubscribe-b], got %v", topics)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "unsubscribe-a",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed, got %v", err)
+	}
+
+	event, err = NewOutgoingEvent(&EventID{
+		Topic: "unsubscribe-b",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = ps.Publish(context.Background(), event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.ID().Topic != "unsubscribe-b" {
+			t.Errorf("Expected topic to be 'unsubscribe-b', got '%s'", e.ID().Topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timeout waiting for event")
+	}
+
+	err = ps.Unsubscribe("unsubscribe-b")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	waitNoSubscriber(t, client, "unsubscribe-b")
+
+	err = ps.Publish(context.Background(), event)
+
+	if !errors.Is(err, ErrNoSubscriberConsumed) {
+		t.Errorf("Expected ErrNoSubscriberConsumed, got %v", err)
+	}
+
+	ps.Stop()
+}
+
+// waitNoSubscriber waits for the server to process the UNSUBSCRIBE command,
+// as redis.PubSub does not wait for the confirmation.
+func waitNoSubscriber(t *testing.T, client *redis.Client, channel string) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		counts, err := client.PubSubNumSub(context.Background(), channel).Result()
+
+		if err == nil && counts[channel] == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Timeout waiting for channel %s to be unsubscribed", channel)
+}
+
+func TestStreamPubSub_Unsubscribe(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := WithStream(client, 0)
+
+	entryIDs := make(map[string]string)
+
+	for _, topic := range []string{"unsubscribe-stream-a", "unsubscribe-stream-b"} {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: topic,
+		}, "/custom", 0, customStruct)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		values := make(map[string]interface{})
+
+		err = event.NormalizeInto(&values)
+
+		if err != nil {
+			t.Errorf("Error normalizing event: %v", err)
+		}
+
+		entryIDs[topic], err = client.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: topic,
+			Values: values,
+		}).Result()
+
+		if err != nil {
+			t.Errorf("Error adding stream entry: %v", err)
+		}
+	}
+
+	err := ps.Subscribe(NewTopic("unsubscribe-stream-a", ""), NewTopic("unsubscribe-stream-b", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ps.Events():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event")
+		}
+	}
+
+	// wait for the worker to sync the offsets of the delivered entries
+	time.Sleep(100 * time.Millisecond)
+
+	err = ps.Unsubscribe("unsubscribe-stream-a")
+
+	if err != nil {
+		t.Errorf("Error unsubscribing: %v", err)
+	}
+
+	topics := ps.Topics()
+
+	if len(topics) != 1 || topics[0] != "unsubscribe-stream-b" {
+		t.Errorf("Expected topics to be [unsubscribe-stream-b], got %v", topics)
+	}
+
+	point, err := ps.Stop()
+
+	if err != nil {
+		t.Errorf("Error stopping pubsub: %v", err)
+	}
+
+	for topic, entryID := range entryIDs {
+		if point.Offsets[topic] != entryID {
+			t.Errorf("Expected offset of %s to be '%s', got '%s'", topic, entryID, point.Offsets[topic])
+		}
+	}
+}
+
+func TestStreamPubSub_ConsumerGroup(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := WithStream(client, 0, WithConsumerGroup("group-test", "consumer-1"))
+
+	err := ps.Subscribe(NewTopic("group-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: "group-stream",
+		}, "/custom", 0, customStruct)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = ps.Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	received := make([]Event, 0)
+
+	for len(received) < 2 {
+		select {
+		case e := <-ps.Events():
+			if _, ok := e.(AckableEvent); !ok {
+				t.Errorf("Expected event to be AckableEvent, got %T", e)
+			}
+
+			received = append(received, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event")
+		}
+	}
+
+	pending, err := client.XPending(context.Background(), "group-stream", "group-test").Result()
+
+	if err != nil {
+		t.Errorf("Error getting pending entries: %v", err)
+	}
+
+	if pending.Count != 2 {
+		t.Errorf("Expected 2 pending entries, got %d", pending.Count)
+	}
+
+	for _, e := range received {
+		err = Ack(context.Background(), e)
+
+		if err != nil {
+			t.Errorf("Error acknowledging event: %v", err)
+		}
+	}
+
+	pending, err = client.XPending(context.Background(), "group-stream", "group-test").Result()
+
+	if err != nil {
+		t.Errorf("Error getting pending entries: %v", err)
+	}
+
+	if pending.Count != 0 {
+		t.Errorf("Expected no pending entries, got %d", pending.Count)
+	}
+
+	ps.Stop()
+}
+
+func TestStreamPubSub_ConsumerGroupLoadBalance(t *testing.T) {
+	client := newMiniRedis(t)
+
+	consumers := []UnifiedPubSub{
+		WithStream(client, 0, WithConsumerGroup("group-balance", "consumer-1")),
+		WithStream(client, 0, WithConsumerGroup("group-balance", "consumer-2")),
+	}
+
+	for _, ps := range consumers {
+		err := ps.Subscribe(NewTopic("group-balance-stream", ""))
+
+		if err != nil {
+			t.Errorf("Error subscribing: %v", err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: "group-balance-stream",
+		}, fmt.Sprintf("/custom-%d", i), 0, nil)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = consumers[0].Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	received := make(map[string]int)
+
+	for len(received) < 4 {
+		select {
+		case e := <-consumers[0].Events():
+			received[e.ID().EntryID]++
+		case e := <-consumers[1].Events():
+			received[e.ID().EntryID]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for events, received %v", received)
+		}
+	}
+
+	select {
+	case e := <-consumers[0].Events():
+		t.Errorf("Unexpected event delivered twice: %v", e.ID())
+	case e := <-consumers[1].Events():
+		t.Errorf("Unexpected event delivered twice: %v", e.ID())
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, ps := range consumers {
+		ps.Stop()
+	}
+}
+
+func TestStreamPubSub_PendingReclaim(t *testing.T) {
+	client := newMiniRedis(t)
+
+	crashed := WithStream(client, 0, WithConsumerGroup("group-reclaim", "consumer-crashed"))
+
+	err := crashed.Subscribe(NewTopic("group-reclaim-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "group-reclaim-stream",
+	}, "/custom", 0, customStruct)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = crashed.Publish(context.Background(), event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	var delivered Event
+
+	select {
+	case delivered = <-crashed.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	// stop without acknowledging the event, as if the consumer crashed
+	crashed.Stop()
+
+	ps := WithStream(client, 0,
+		WithConsumerGroup("group-reclaim", "consumer-alive"),
+		WithPendingReclaim(50*time.Millisecond, 50*time.Millisecond),
+	)
+
+	err = ps.Subscribe(NewTopic("group-reclaim-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.ID() != delivered.ID() {
+			t.Errorf("Expected reclaimed event %v, got %v", delivered.ID(), e.ID())
+		}
+
+		ackable, ok := e.(AckableEvent)
+
+		if !ok {
+			t.Fatalf("Expected event to be AckableEvent, got %T", e)
+		}
+
+		if ackable.DeliveryCount() != 2 {
+			t.Errorf("Expected delivery count to be 2, got %d", ackable.DeliveryCount())
+		}
+
+		err = ackable.Ack(context.Background())
+
+		if err != nil {
+			t.Errorf("Error acknowledging event: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for reclaimed event")
+	}
+
+	ps.Stop()
+}
+
+func TestStreamWorker_DeliveryCounts(t *testing.T) {
+	client := newMiniRedis(t)
+	ctx := context.Background()
+
+	err := client.XGroupCreateMkStream(ctx, "delivery-counts", "group-counts", string(MinimumID)).Err()
+
+	if err != nil {
+		t.Fatalf("Error creating group: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		addStreamEntry(t, client, "delivery-counts", fmt.Sprintf("/custom-%d", i))
+	}
+
+	// the entries are pending on a dead consumer
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "group-counts",
+		Consumer: "consumer-dead",
+		Streams:  []string{"delivery-counts", string(LastDeliveredID)},
+	}).Result()
+
+	if err != nil || len(streams) != 1 || len(streams[0].Messages) != 3 {
+		t.Fatalf("Error reading the entries: %v", err)
+	}
+
+	messages := streams[0].Messages
+	ids := []string{messages[0].ID, messages[1].ID, messages[2].ID}
+
+	// the middle one was claimed by the live consumer before, and the others are claimed now
+	for _, claimed := range [][]string{ids[1:2], {ids[0], ids[2]}} {
+		err = client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   "delivery-counts",
+			Group:    "group-counts",
+			Consumer: "consumer-alive",
+			Messages: claimed,
+		}).Err()
+
+		if err != nil {
+			t.Fatalf("Error claiming the entries: %v", err)
+		}
+	}
+
+	w := NewStreamWorker(client, 0, WithConsumerGroup("group-counts", "consumer-alive")).(*streamWorkerImpl)
+
+	counts, err := w.deliveryCounts("delivery-counts", []redis.XMessage{messages[0], messages[2]})
+
+	if err != nil {
+		t.Fatalf("Error getting the delivery counts: %v", err)
+	}
+
+	// the middle entry pending on the same consumer does not take the place of the claimed ones
+	for _, id := range []string{ids[0], ids[2]} {
+		if counts[id] != 2 {
+			t.Errorf("Expected the delivery count of %s to be 2, got %v", id, counts)
+		}
+	}
+}
+
+func TestStreamPubSub_IdleStream(t *testing.T) {
+	client := newMiniRedis(t)
+
+	ps := WithStream(client, 0, WithStreamRead(20*time.Millisecond, 2))
+
+	err := client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: "idle-stream",
+		Values: map[string]interface{}{
+			EventActionKey:    "/init",
+			EventTimestampKey: time.Now().UnixMilli(),
+		},
+	}).Err()
+
+	if err != nil {
+		t.Fatalf("Error adding stream entry: %v", err)
+	}
+
+	err = ps.Subscribe(NewTopic("idle-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	select {
+	case <-ps.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	// let several blocking reads time out on the idle stream
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: "idle-stream",
+		}, fmt.Sprintf("/custom-%d", i), 0, nil)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = ps.Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case e := <-ps.Events():
+			if e.Action() != fmt.Sprintf("/custom-%d", i) {
+				t.Errorf("Expected action to be '/custom-%d', got '%s'", i, e.Action())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event %d", i)
+		}
+	}
+
+	ps.Stop()
+}
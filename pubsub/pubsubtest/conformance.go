@@ -0,0 +1,573 @@
+// Package pubsubtest provides a conformance suite for the implementations of pubsub.UnifiedPubSub,
+// so that a new backend can prove it behaves like the Redis ones.
+package pubsubtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout is how long the suite waits for an event or an error by default.
+const DefaultTimeout = 5 * time.Second
+
+// Backend describes how the suite creates and prepares the instances of a backend.
+type Backend struct {
+	// New creates an instance of the backend.
+	// The instances created by the same Backend must share the published events,
+	// e.g. connect to the same server, so that one can resume from the SyncPoint of another.
+	New func(t *testing.T) pubsub.UnifiedPubSub
+
+	// Prepare prepares a topic before anything is published to or subscribed from it,
+	// e.g. creating a Redis stream or a Kafka topic. It is optional.
+	Prepare func(t *testing.T, topic string)
+
+	// RequiresPrepare reports whether Publish fails on a topic which is not prepared,
+	// like Redis Stream, which does not create the stream.
+	RequiresPrepare bool
+
+	// Topic creates the topic to subscribe for the first time.
+	// It is optional, and defaults to pubsub.NewTopic(name, "").
+	Topic func(name string) pubsub.Topic
+
+	// Retained reports whether the backend keeps the published events, like Redis Stream,
+	// so that a topic resumed from the SyncPoint receives the events published while it was stopped.
+	// Otherwise, the backend is expected to behave like Redis PubSub.
+	Retained bool
+
+	// Timeout is how long to wait for an event or an error; it defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Context derives the context to publish with, e.g. carrying a span so that its trace context is injected.
+	// It is optional.
+	Context func(ctx context.Context) context.Context
+}
+
+// Factory creates a fresh Backend for each test of the suite, e.g. connected to a new miniredis server.
+type Factory func(t *testing.T) Backend
+
+// RunConformance runs the conformance suite against the backend created by the factory.
+// Every test runs as a subtest of t, with its own Backend and topic names.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s *suite)
+	}{
+		{"PublishSubscribe", testPublishSubscribe},
+		{"MultipleTopics", testMultipleTopics},
+		{"Resubscribe", testResubscribe},
+		{"StopClosesChannels", testStopClosesChannels},
+		{"SyncPointRoundTrip", testSyncPointRoundTrip},
+		{"ConcurrentSubscribe", testConcurrentSubscribe},
+		{"ErrorReporting", testErrorReporting},
+		{"PublishUnprepared", testPublishUnprepared},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newSuite(t, factory(t)))
+		})
+	}
+}
+
+type suite struct {
+	Backend
+
+	t *testing.T
+}
+
+func newSuite(t *testing.T, b Backend) *suite {
+	if b.New == nil {
+		t.Fatalf("Backend.New is required")
+	}
+
+	if b.Timeout <= 0 {
+		b.Timeout = DefaultTimeout
+	}
+
+	return &suite{
+		Backend: b,
+		t:       t,
+	}
+}
+
+// topic returns a topic name unique to the test, and prepares it.
+func (s *suite) topic(name string) string {
+	s.t.Helper()
+
+	unique := s.unpreparedTopic(name)
+
+	if s.Prepare != nil {
+		s.Prepare(s.t, unique)
+	}
+
+	return unique
+}
+
+// unpreparedTopic returns a topic name unique to the test, without preparing it.
+func (s *suite) unpreparedTopic(name string) string {
+	return fmt.Sprintf("%s-%s-%d", strings.ReplaceAll(s.t.Name(), "/", "-"), name, time.Now().UnixNano())
+}
+
+func (s *suite) newTopic(name string) pubsub.Topic {
+	if s.Topic != nil {
+		return s.Topic(name)
+	}
+
+	return pubsub.NewTopic(name, "")
+}
+
+// start creates an instance which is stopped when the test finishes.
+func (s *suite) start() pubsub.UnifiedPubSub {
+	s.t.Helper()
+
+	ps := s.New(s.t)
+
+	s.t.Cleanup(func() {
+		_, _ = ps.Stop()
+	})
+
+	return ps
+}
+
+func (s *suite) subscribe(ps pubsub.UnifiedPubSub, names ...string) {
+	s.t.Helper()
+
+	topics := make([]pubsub.Topic, 0)
+
+	for _, name := range names {
+		topics = append(topics, s.newTopic(name))
+	}
+
+	if err := ps.Subscribe(topics...); err != nil {
+		s.t.Fatalf("Error subscribing %v: %v", names, err)
+	}
+
+	settle()
+}
+
+// settle waits for the clock to move on after subscribing,
+// as the stream backends drop the events created in the same millisecond as a subscription (see pubsub.WithStream).
+func settle() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+// context returns the context to publish with, which times out after s.Timeout.
+func (s *suite) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+
+	if s.Context != nil {
+		ctx = s.Context(ctx)
+	}
+
+	return ctx, cancel
+}
+
+// publish publishes an event with the action to the topic.
+// The error is returned without failing the test, as Redis PubSub fails without subscribers.
+func (s *suite) publish(ps pubsub.UnifiedPubSub, topic, action string) error {
+	s.t.Helper()
+
+	event, err := pubsub.NewOutgoingEvent(&pubsub.EventID{
+		Topic: topic,
+	}, action, 0, payload{Topic: topic, Action: action})
+
+	if err != nil {
+		s.t.Fatalf("Error creating event: %v", err)
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	return ps.Publish(ctx, event)
+}
+
+func (s *suite) mustPublish(ps pubsub.UnifiedPubSub, topic, action string) {
+	s.t.Helper()
+
+	if err := s.publish(ps, topic, action); err != nil {
+		s.t.Fatalf("Error publishing %s to %s: %v", action, topic, err)
+	}
+}
+
+// receive waits for the next event, and checks that it carries its own topic and action in the payload.
+func (s *suite) receive(ps pubsub.UnifiedPubSub) pubsub.Event {
+	s.t.Helper()
+
+	select {
+	case e, ok := <-ps.Events():
+		if !ok {
+			s.t.Fatalf("Events() closed while waiting for an event")
+		}
+
+		p := payload{}
+
+		if err := e.UnmarshalPayload(&p); err != nil {
+			s.t.Fatalf("Error unmarshalling payload of %s: %v", e.Action(), err)
+		}
+
+		if p.Topic != e.ID().Topic || p.Action != e.Action() {
+			s.t.Errorf("Expected event of %s %s, got payload %+v", e.ID().Topic, e.Action(), p)
+		}
+
+		return e
+	case <-time.After(s.Timeout):
+		s.t.Fatalf("Timeout waiting for event")
+	}
+
+	return nil
+}
+
+func (s *suite) expect(ps pubsub.UnifiedPubSub, topic, action string) pubsub.Event {
+	s.t.Helper()
+
+	e := s.receive(ps)
+
+	if e.ID().Topic != topic || e.Action() != action {
+		s.t.Errorf("Expected event %s from %s, got %s from %s", action, topic, e.Action(), e.ID().Topic)
+	}
+
+	return e
+}
+
+type payload struct {
+	Topic  string `json:"topic"`
+	Action string `json:"action"`
+}
+
+func testPublishSubscribe(t *testing.T, s *suite) {
+	topic := s.topic("topic")
+
+	ps := s.start()
+
+	s.subscribe(ps, topic)
+
+	if topics := ps.Topics(); len(topics) != 1 || topics[0] != topic {
+		t.Errorf("Expected Topics() to be [%s], got %v", topic, topics)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.mustPublish(ps, topic, fmt.Sprintf("/action-%d", i))
+	}
+
+	// the events of a topic are delivered in order
+	for i := 0; i < 3; i++ {
+		s.expect(ps, topic, fmt.Sprintf("/action-%d", i))
+	}
+}
+
+func testMultipleTopics(t *testing.T, s *suite) {
+	first, second := s.topic("first"), s.topic("second")
+
+	ps := s.start()
+
+	s.subscribe(ps, first, second)
+
+	s.mustPublish(ps, first, "/first")
+	s.mustPublish(ps, second, "/second")
+
+	received := make(map[string]string)
+
+	for i := 0; i < 2; i++ {
+		e := s.receive(ps)
+		received[e.ID().Topic] = e.Action()
+	}
+
+	if received[first] != "/first" || received[second] != "/second" {
+		t.Errorf("Expected an event from each topic, got %v", received)
+	}
+}
+
+func testResubscribe(t *testing.T, s *suite) {
+	first, second := s.topic("first"), s.topic("second")
+
+	ps := s.start()
+
+	s.subscribe(ps, first)
+	s.mustPublish(ps, first, "/before")
+	s.expect(ps, first, "/before")
+
+	// subscribing another topic keeps the subscribed one
+	s.subscribe(ps, second)
+	s.mustPublish(ps, first, "/first")
+	s.expect(ps, first, "/first")
+	s.mustPublish(ps, second, "/second")
+	s.expect(ps, second, "/second")
+
+	if err := ps.Unsubscribe(first); err != nil {
+		t.Fatalf("Error unsubscribing: %v", err)
+	}
+
+	if topics := ps.Topics(); len(topics) != 1 || topics[0] != second {
+		t.Errorf("Expected Topics() to be [%s], got %v", second, topics)
+	}
+
+	// the unsubscribed topic is no longer delivered, so the next event comes from the other one
+	_ = s.publish(ps, first, "/unsubscribed")
+	s.mustPublish(ps, second, "/after")
+	s.expect(ps, second, "/after")
+
+	s.subscribe(ps, first)
+	s.mustPublish(ps, first, "/resubscribed")
+
+	for {
+		e := s.receive(ps)
+
+		if e.ID().Topic == first && e.Action() == "/resubscribed" {
+			break
+		}
+
+		// a retained topic may deliver what was published while it was unsubscribed
+		if !s.Retained || e.ID().Topic != first || e.Action() != "/unsubscribed" {
+			t.Fatalf("Unexpected event %s from %s", e.Action(), e.ID().Topic)
+		}
+	}
+}
+
+func testStopClosesChannels(t *testing.T, s *suite) {
+	topic := s.topic("topic")
+
+	ps := s.New(t)
+
+	s.subscribe(ps, topic)
+
+	if _, err := ps.Stop(); err != nil {
+		t.Errorf("Error stopping: %v", err)
+	}
+
+	select {
+	case _, ok := <-ps.Events():
+		if ok {
+			t.Errorf("Expected Events() to be closed")
+		}
+	case <-time.After(s.Timeout):
+		t.Fatalf("Timeout waiting for Events() to be closed")
+	}
+
+	// the buffered errors may be drained before the channel is closed
+	timeout := time.After(s.Timeout)
+
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-ps.Errors():
+			closed = !ok
+		case <-timeout:
+			t.Fatalf("Timeout waiting for Errors() to be closed")
+		}
+	}
+
+	if err := ps.Subscribe(s.newTopic(topic)); !errors.Is(err, pubsub.ErrPubSubStopped) {
+		t.Errorf("Expected ErrPubSubStopped when subscribing after Stop, got %v", err)
+	}
+
+	if _, err := ps.Stop(); err != nil {
+		t.Errorf("Expected stopping again to be a no-op, got %v", err)
+	}
+
+	// an instance which never subscribes is stopped as well
+	idle := s.New(t)
+
+	if _, err := idle.Stop(); err != nil {
+		t.Errorf("Error stopping: %v", err)
+	}
+
+	if _, ok := <-idle.Events(); ok {
+		t.Errorf("Expected Events() to be closed")
+	}
+}
+
+func testSyncPointRoundTrip(t *testing.T, s *suite) {
+	topic := s.topic("topic")
+
+	ps := s.New(t)
+
+	s.subscribe(ps, topic)
+	s.mustPublish(ps, topic, "/first")
+	s.mustPublish(ps, topic, "/second")
+	s.expect(ps, topic, "/first")
+	s.expect(ps, topic, "/second")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	point, err := ps.Shutdown(ctx)
+
+	if err != nil {
+		t.Fatalf("Error shutting down: %v", err)
+	}
+
+	if !s.Retained {
+		return
+	}
+
+	if _, ok := point.Offsets[topic]; !ok {
+		t.Fatalf("Expected SyncPoint to carry the offset of %s, got %v", topic, point.Offsets)
+	}
+
+	path := filepath.Join(t.TempDir(), "sync-point.json")
+
+	if err = pubsub.DumpSyncPoint(path, &point); err != nil {
+		t.Fatalf("Error dumping SyncPoint: %v", err)
+	}
+
+	loaded, err := pubsub.LoadSyncPoint(path)
+
+	if err != nil {
+		t.Fatalf("Error loading SyncPoint: %v", err)
+	}
+
+	// the events published while stopped are delivered after resuming, but not the ones delivered before
+	publisher := s.start()
+	s.mustPublish(publisher, topic, "/third")
+
+	resumed := s.start()
+
+	if err = resumed.Subscribe(loaded.AsTopics()...); err != nil {
+		t.Fatalf("Error subscribing from SyncPoint: %v", err)
+	}
+
+	s.expect(resumed, topic, "/third")
+}
+
+func testConcurrentSubscribe(t *testing.T, s *suite) {
+	const count = 8
+
+	topics := make([]string, count)
+
+	for i := range topics {
+		topics[i] = s.topic(fmt.Sprintf("topic-%d", i))
+	}
+
+	ps := s.start()
+
+	wg := &sync.WaitGroup{}
+
+	for _, topic := range topics {
+		wg.Add(1)
+
+		go func(topic string) {
+			defer wg.Done()
+
+			if err := ps.Subscribe(s.newTopic(topic)); err != nil {
+				t.Errorf("Error subscribing %s: %v", topic, err)
+			}
+
+			_ = ps.Topics()
+		}(topic)
+	}
+
+	wg.Wait()
+
+	if subscribed := ps.Topics(); len(subscribed) != count {
+		t.Fatalf("Expected %d topics, got %v", count, subscribed)
+	}
+
+	settle()
+
+	for _, topic := range topics {
+		s.mustPublish(ps, topic, "/concurrent")
+	}
+
+	received := make(map[string]bool)
+
+	for range topics {
+		received[s.receive(ps).ID().Topic] = true
+	}
+
+	for _, topic := range topics {
+		if !received[topic] {
+			t.Errorf("Expected an event from %s, got %v", topic, received)
+		}
+	}
+}
+
+func testErrorReporting(t *testing.T, s *suite) {
+	topic := s.topic("topic")
+
+	ps := s.start()
+
+	s.subscribe(ps, topic)
+
+	valid, err := pubsub.NewOutgoingEvent(&pubsub.EventID{
+		Topic: topic,
+	}, "/malformed", 0, nil)
+
+	if err != nil {
+		t.Fatalf("Error creating event: %v", err)
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if err = ps.Publish(ctx, &malformedEvent{Event: valid}); err != nil {
+		t.Fatalf("Error publishing malformed event: %v", err)
+	}
+
+	select {
+	case err := <-ps.Errors():
+		var parseErr *pubsub.ParseError
+
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Expected ParseError, got %T: %v", err, err)
+		} else if parseErr.Topic != topic {
+			t.Errorf("Expected ParseError of %s, got %s", topic, parseErr.Topic)
+		}
+	case <-time.After(s.Timeout):
+		t.Fatalf("Timeout waiting for ParseError")
+	}
+
+	// the malformed event is dropped, and the worker keeps delivering
+	s.mustPublish(ps, topic, "/valid")
+	s.expect(ps, topic, "/valid")
+}
+
+func testPublishUnprepared(t *testing.T, s *suite) {
+	if !s.RequiresPrepare {
+		t.Skip("the backend does not require the topics to be prepared")
+	}
+
+	topic := s.unpreparedTopic("topic")
+
+	ps := s.start()
+
+	if err := s.publish(ps, topic, "/unprepared"); err == nil {
+		t.Errorf("Expected publishing to the unprepared %s to fail", topic)
+	}
+
+	// the topic works once prepared
+	s.Prepare(t, topic)
+	s.subscribe(ps, topic)
+
+	s.mustPublish(ps, topic, "/prepared")
+	s.expect(ps, topic, "/prepared")
+}
+
+// malformedEvent normalizes into data without the action, which no backend can parse back into an Event.
+type malformedEvent struct {
+	pubsub.Event
+}
+
+func (e *malformedEvent) NormalizeInto(target interface{}) error {
+	if err := e.Event.NormalizeInto(target); err != nil {
+		return err
+	}
+
+	switch target := target.(type) {
+	case *map[string]interface{}:
+		delete(*target, pubsub.EventActionKey)
+	case *string:
+		*target = `{"payload":"malformed"}`
+	case *[]byte:
+		*target = []byte(`{"payload":"malformed"}`)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package pubsubtest
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/edgejumps/sportstalk-pubsub/pubsub"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+func newMiniRedis(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func TestConformance_RedisPubSub(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Backend {
+		client := newMiniRedis(t)
+
+		return Backend{
+			New: func(t *testing.T) pubsub.UnifiedPubSub {
+				return pubsub.New(client)
+			},
+		}
+	})
+}
+
+func TestConformance_RedisStream(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Backend {
+		client := newMiniRedis(t)
+
+		return Backend{
+			New: func(t *testing.T) pubsub.UnifiedPubSub {
+				return pubsub.WithStream(client, 0, pubsub.WithStreamRead(20*time.Millisecond, 0))
+			},
+			// Publish does not create the stream
+			Prepare: func(t *testing.T, topic string) {
+				err := client.XGroupCreateMkStream(context.Background(), topic, "conformance", string(pubsub.MaximumID)).Err()
+
+				if err != nil {
+					t.Fatalf("Error creating stream: %v", err)
+				}
+			},
+			RequiresPrepare: true,
+			Retained:        true,
+		}
+	})
+}
+
+func TestConformance_Memory(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Backend {
+		broker := pubsub.NewMemoryBroker()
+
+		return Backend{
+			New: func(t *testing.T) pubsub.UnifiedPubSub {
+				return pubsub.WithMemory(pubsub.WithMemoryBroker(broker))
+			},
+		}
+	})
+}
+
+func TestConformance_MemoryStream(t *testing.T) {
+	RunConformance(t, func(t *testing.T) Backend {
+		broker := pubsub.NewMemoryBroker()
+
+		return Backend{
+			New: func(t *testing.T) pubsub.UnifiedPubSub {
+				return pubsub.WithMemoryStream(0, pubsub.WithMemoryBroker(broker))
+			},
+			// Publish does not create the stream, as WithStream
+			Prepare: func(t *testing.T, topic string) {
+				broker.CreateStream(topic)
+			},
+			RequiresPrepare: true,
+			Retained:        true,
+		}
+	})
+}
+
+// TestConformance_TraceContext runs the suite with the W3C trace context injected into every published event,
+// including the custom ones of the suite, e.g. the malformed event of ErrorReporting.
+func TestConformance_TraceContext(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(previous)
+	})
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	traced := func(ctx context.Context) context.Context {
+		return trace.ContextWithSpanContext(ctx, spanContext)
+	}
+
+	t.Run("RedisPubSub", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) Backend {
+			client := newMiniRedis(t)
+
+			return Backend{
+				New: func(t *testing.T) pubsub.UnifiedPubSub {
+					return pubsub.New(client)
+				},
+				Context: traced,
+			}
+		})
+	})
+
+	t.Run("MemoryStream", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T) Backend {
+			broker := pubsub.NewMemoryBroker()
+
+			return Backend{
+				New: func(t *testing.T) pubsub.UnifiedPubSub {
+					return pubsub.WithMemoryStream(0, pubsub.WithMemoryBroker(broker))
+				},
+				Prepare: func(t *testing.T, topic string) {
+					broker.CreateStream(topic)
+				},
+				RequiresPrepare: true,
+				Retained:        true,
+				Context:         traced,
+			}
+		})
+	})
+}
+
+func TestConformance_Kafka(t *testing.T) {
+	if testing.Short() {
+		t.Skip("joining the consumer groups of the mock cluster is slow")
+	}
+
+	RunConformance(t, func(t *testing.T) Backend {
+		cluster, err := kafka.NewMockCluster(1)
+
+		if err != nil {
+			t.Fatalf("Error creating mock cluster: %v", err)
+		}
+
+		t.Cleanup(cluster.Close)
+
+		config := &kafka.ConfigMap{
+			"bootstrap.servers":  cluster.BootstrapServers(),
+			"session.timeout.ms": 6000,
+		}
+
+		return Backend{
+			New: func(t *testing.T) pubsub.UnifiedPubSub {
+				ps, err := pubsub.WithKafka(config)
+
+				if err != nil {
+					t.Fatalf("Error creating kafka pubsub: %v", err)
+				}
+
+				return ps
+			},
+			Prepare: func(t *testing.T, topic string) {
+				if err := cluster.CreateTopic(topic, 1, 1); err != nil {
+					t.Fatalf("Error creating topic: %v", err)
+				}
+			},
+			// every topic is consumed within its own consumer group
+			Topic: func(name string) pubsub.Topic {
+				return pubsub.NewTopic(name, name+"-group")
+			},
+			Retained: true,
+			Timeout:  30 * time.Second,
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultReconnectMinBackoff = 100 * time.Millisecond
+	DefaultReconnectMaxBackoff = 10 * time.Second
+)
+
+// DisconnectedError reports that a worker lost its connection to the server.
+// The worker keeps retrying with exponential backoff until it reconnects or stops.
+type DisconnectedError struct {
+	Topics []string
+	Err    error
+}
+
+func (e *DisconnectedError) Error() string {
+	return fmt.Sprintf("disconnected from [%s]: %v", strings.Join(e.Topics, ", "), e.Err)
+}
+
+func (e *DisconnectedError) Unwrap() error {
+	return e.Err
+}
+
+// ReconnectedStatus reports that a worker has reconnected and resumed its topics after a DisconnectedError.
+// It is not a failure but a status, which implements error only to be delivered through Errors()
+// along with the DisconnectedError it follows.
+type ReconnectedStatus struct {
+	Topics   []string
+	Attempts int
+	Downtime time.Duration
+}
+
+func (e *ReconnectedStatus) Error() string {
+	return fmt.Sprintf("reconnected to [%s] after %d attempts (%v)", strings.Join(e.Topics, ", "), e.Attempts, e.Downtime)
+}
+
+// reconnector tracks the connection state of a worker,
+// reporting each disconnect/reconnect and pacing the retries with exponential backoff and jitter.
+type reconnector struct {
+	options *options
+
+	// op is the command retried by the worker, reported in TransportError
+	op string
+
+	topics func() []string
+
+	attempts     int
+	disconnected time.Time
+}
+
+func newReconnector(o *options, op string, topics func() []string) *reconnector {
+	return &reconnector{
+		options: o,
+		op:      op,
+		topics:  topics,
+	}
+}
+
+// failed records a failed attempt and waits for the backoff. It returns false if the context is done while waiting.
+// The first failure of the connection is reported as DisconnectedError, while an error replied by the server
+// (e.g. WRONGTYPE) is reported as TransportError every time, as the connection itself is fine.
+func (r *reconnector) failed(ctx context.Context, err error) bool {
+	var serverErr redis.Error
+
+	if errors.As(err, &serverErr) {
+		r.options.reportError(&TransportError{
+			Op:     r.op,
+			Topics: r.topics(),
+			Err:    err,
+		})
+	} else if r.disconnected.IsZero() {
+		r.disconnected = time.Now()
+
+		r.options.reportError(&DisconnectedError{
+			Topics: r.topics(),
+			Err:    err,
+		})
+	}
+
+	r.attempts++
+
+	timer := time.NewTimer(Backoff(r.attempts, r.options.minBackoff, r.options.maxBackoff))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// succeeded records a successful round trip, reporting the reconnection if the worker was disconnected.
+func (r *reconnector) succeeded() {
+	if !r.disconnected.IsZero() {
+		r.options.reportError(&ReconnectedStatus{
+			Topics:   r.topics(),
+			Attempts: r.attempts,
+			Downtime: time.Since(r.disconnected),
+		})
+	}
+
+	r.attempts = 0
+	r.disconnected = time.Time{}
+}
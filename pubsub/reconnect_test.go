@@ -0,0 +1,296 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tcpProxy forwards connections to the target, and can be killed and restored on the same address
+// to simulate a network failure between the client and the server.
+type tcpProxy struct {
+	t *testing.T
+
+	addr   string
+	target string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+}
+
+func newTCPProxy(t *testing.T, target string) *tcpProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+
+	p := &tcpProxy{
+		t:        t,
+		addr:     listener.Addr().String(),
+		target:   target,
+		listener: listener,
+	}
+
+	go p.serve(listener)
+
+	t.Cleanup(p.Kill)
+
+	return p
+}
+
+func (p *tcpProxy) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		upstream, err := net.Dial("tcp", p.target)
+
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+
+		p.mu.Lock()
+		p.conns = append(p.conns, conn, upstream)
+		p.mu.Unlock()
+
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			_ = upstream.Close()
+		}()
+
+		go func() {
+			_, _ = io.Copy(conn, upstream)
+			_ = conn.Close()
+		}()
+	}
+}
+
+// Kill stops accepting connections and drops all established ones.
+func (p *tcpProxy) Kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.listener != nil {
+		_ = p.listener.Close()
+		p.listener = nil
+	}
+
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+
+	p.conns = nil
+}
+
+// Restore accepts connections on the same address again.
+func (p *tcpProxy) Restore() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	listener, err := net.Listen("tcp", p.addr)
+
+	if err != nil {
+		p.t.Fatalf("Error listening: %v", err)
+	}
+
+	p.listener = listener
+
+	go p.serve(listener)
+}
+
+func newProxiedRedis(t *testing.T) (direct *redis.Client, proxied *redis.Client, proxy *tcpProxy) {
+	server := miniredis.RunT(t)
+
+	proxy = newTCPProxy(t, server.Addr())
+
+	direct = redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+
+	proxied = redis.NewClient(&redis.Options{
+		Addr:        proxy.addr,
+		DialTimeout: 100 * time.Millisecond,
+	})
+
+	t.Cleanup(func() {
+		_ = direct.Close()
+		_ = proxied.Close()
+	})
+
+	return direct, proxied, proxy
+}
+
+func waitError[T error](t *testing.T, errs <-chan error) T {
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case err := <-errs:
+			var target T
+
+			if errors.As(err, &target) {
+				return target
+			}
+		case <-timeout:
+			var target T
+
+			t.Fatalf("Timeout waiting for %T", target)
+
+			return target
+		}
+	}
+}
+
+func TestStreamPubSub_Reconnect(t *testing.T) {
+	direct, proxied, proxy := newProxiedRedis(t)
+
+	publisher := WithStream(direct, 0)
+
+	ps := WithStream(proxied, 0,
+		WithStreamRead(50*time.Millisecond, 10),
+		WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond),
+	)
+
+	err := direct.XGroupCreateMkStream(context.Background(), "reconnect-stream", "unused", "0").Err()
+
+	if err != nil {
+		t.Fatalf("Error creating stream: %v", err)
+	}
+
+	err = ps.Subscribe(NewTopic("reconnect-stream", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	publish := func(action string) {
+		event, err := NewOutgoingEvent(&EventID{
+			Topic: "reconnect-stream",
+		}, action, 0, nil)
+
+		if err != nil {
+			t.Errorf("Error creating new event: %v", err)
+		}
+
+		err = publisher.Publish(context.Background(), event)
+
+		if err != nil {
+			t.Errorf("Error publishing message: %v", err)
+		}
+	}
+
+	receive := func(action string) {
+		select {
+		case e := <-ps.Events():
+			if e.Action() != action {
+				t.Errorf("Expected action to be '%s', got '%s'", action, e.Action())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for event %s", action)
+		}
+	}
+
+	publish("/before")
+	receive("/before")
+
+	proxy.Kill()
+
+	disconnected := waitError[*DisconnectedError](t, ps.Errors())
+
+	if len(disconnected.Topics) != 1 || disconnected.Topics[0] != "reconnect-stream" {
+		t.Errorf("Expected disconnected topics to be [reconnect-stream], got %v", disconnected.Topics)
+	}
+
+	// published while disconnected, it should be read from the last offset after reconnecting
+	publish("/during")
+
+	proxy.Restore()
+
+	reconnected := waitError[*ReconnectedStatus](t, ps.Errors())
+
+	if reconnected.Attempts == 0 {
+		t.Errorf("Expected reconnection attempts to be reported")
+	}
+
+	receive("/during")
+
+	ps.Stop()
+}
+
+func TestRedisPubSub_Reconnect(t *testing.T) {
+	direct, proxied, proxy := newProxiedRedis(t)
+
+	publisher := New(direct)
+
+	ps := New(proxied, WithReconnectBackoff(10*time.Millisecond, 50*time.Millisecond))
+
+	err := ps.Subscribe(NewTopic("reconnect-channel", ""))
+
+	if err != nil {
+		t.Errorf("Error subscribing: %v", err)
+	}
+
+	proxy.Kill()
+
+	waitError[*DisconnectedError](t, ps.Errors())
+
+	proxy.Restore()
+
+	waitError[*ReconnectedStatus](t, ps.Errors())
+
+	event, err := NewOutgoingEvent(&EventID{
+		Topic: "reconnect-channel",
+	}, "/after", 0, nil)
+
+	if err != nil {
+		t.Errorf("Error creating new event: %v", err)
+	}
+
+	err = publisher.Publish(context.Background(), event)
+
+	if err != nil {
+		t.Errorf("Error publishing message: %v", err)
+	}
+
+	select {
+	case e := <-ps.Events():
+		if e.Action() != "/after" {
+			t.Errorf("Expected action to be '/after', got '%s'", e.Action())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for event")
+	}
+
+	ps.Stop()
+}
+
+func TestBackoff(t *testing.T) {
+	minBackoff := 100 * time.Millisecond
+	maxBackoff := time.Second
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		expected := minBackoff << (attempt - 1)
+
+		if expected > maxBackoff {
+			expected = maxBackoff
+		}
+
+		delay := Backoff(attempt, minBackoff, maxBackoff)
+
+		if delay < expected/2 || delay > expected {
+			t.Errorf("Expected backoff of attempt %d within [%v, %v], got %v", attempt, expected/2, expected, delay)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRequestTimeout = 5 * time.Second
+
+	// HeaderCorrelationID matches the reply to its request.
+	HeaderCorrelationID = "correlation-id"
+
+	// HeaderReplyTo is the topic where the reply to the request is expected.
+	HeaderReplyTo = "reply-to"
+
+	// HeaderReplyError is the error of the handler of the request, carried by the reply instead of a payload.
+	HeaderReplyError = "reply-error"
+)
+
+var (
+	ErrRequestTimeout = errors.New("request timed out")
+	ErrNotRequest     = errors.New("event is not a request")
+)
+
+// RemoteError is the error replied by the handler of a request, see HeaderReplyError.
+type RemoteError struct {
+	Action  string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("request %s failed: %s", e.Action, e.Message)
+}
+
+// RequesterOption configures a Requester.
+type RequesterOption func(*Requester)
+
+// WithRequestTimeout sets how long Request waits for the reply, unless ctx is done earlier.
+// The default is DefaultRequestTimeout.
+func WithRequestTimeout(timeout time.Duration) RequesterOption {
+	return func(r *Requester) {
+		if timeout > 0 {
+			r.timeout = timeout
+		}
+	}
+}
+
+// Requester publishes the requests, and waits for their replies from the reply topic (request/reply).
+// The requests are stamped with HeaderCorrelationID and HeaderReplyTo,
+// and the handlers reply via NewReplyEvent (e.g. eventhandler.Reply) into the reply topic.
+//
+// It works over the PubSub of New, WithStream, WithMemory and WithMemoryStream.
+// The reply topic can be shared by several requesters, as the replies to the others are ignored.
+type Requester struct {
+	ps         UnifiedPubSub
+	replyTopic string
+	timeout    time.Duration
+
+	mu      *sync.Mutex
+	pending map[string]chan Event
+	stopped bool
+}
+
+// NewRequester subscribes ps to the reply topic, and receives the replies from its Events() channel,
+// so ps must be dedicated to the Requester; it is also used to publish the requests.
+// For Redis Stream, the reply topic is read from the time the Requester is created,
+// and the streams of the requests and the replies must exist, as Publish does not create them.
+func NewRequester(ps UnifiedPubSub, replyTopic string, opts ...RequesterOption) (*Requester, error) {
+	r := &Requester{
+		ps:         ps,
+		replyTopic: replyTopic,
+		timeout:    DefaultRequestTimeout,
+		mu:         &sync.Mutex{},
+		pending:    make(map[string]chan Event),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// the entries added before are skipped, as the entry IDs start with the unix milliseconds;
+	// the millisecond is backed off, so that a reply added within the same millisecond is not skipped
+	offset := strconv.FormatInt(time.Now().UnixMilli()-1, 10) + "-0"
+
+	if err := ps.Subscribe(NewTopic(replyTopic, offset)); err != nil {
+		return nil, err
+	}
+
+	go r.receive()
+
+	return r, nil
+}
+
+// receive hands the replies to the pending requests until the Events() channel is closed.
+func (r *Requester) receive() {
+	for event := range r.ps.Events() {
+		id := event.Headers()[HeaderCorrelationID]
+
+		r.mu.Lock()
+
+		if reply, ok := r.pending[id]; ok {
+			delete(r.pending, id)
+			reply <- event
+		}
+
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+
+	for id, reply := range r.pending {
+		delete(r.pending, id)
+		close(reply)
+	}
+}
+
+// Request publishes the request, and returns its reply.
+// It returns *RemoteError if the handler replies with an error, ErrRequestTimeout if no reply arrives in time,
+// or ctx.Err() if ctx is cancelled; ErrPubSubStopped is returned once the PubSub is stopped.
+func (r *Requester) Request(ctx context.Context, request Event) (Event, error) {
+	id, err := newCorrelationID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	request, err = withHeaders(request, map[string]string{
+		HeaderCorrelationID: id,
+		HeaderReplyTo:       r.replyTopic,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(chan Event, 1)
+
+	r.mu.Lock()
+
+	if r.stopped {
+		r.mu.Unlock()
+		return nil, ErrPubSubStopped
+	}
+
+	r.pending[id] = reply
+
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		delete(r.pending, id)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := r.ps.Publish(ctx, request); err != nil {
+		return nil, err
+	}
+
+	select {
+	case event, ok := <-reply:
+		if !ok {
+			return nil, ErrPubSubStopped
+		}
+
+		if message, failed := event.Headers()[HeaderReplyError]; failed {
+			return event, &RemoteError{Action: request.Action(), Message: message}
+		}
+
+		return event, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s", ErrRequestTimeout, request.Action())
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+// Stop stops the PubSub of the Requester, failing the pending requests with ErrPubSubStopped.
+func (r *Requester) Stop() error {
+	_, err := r.ps.Stop()
+
+	return err
+}
+
+// IsRequest reports whether the event is a request expecting a reply, i.e. it has HeaderReplyTo and HeaderCorrelationID.
+func IsRequest(event Event) bool {
+	headers := event.Headers()
+
+	return headers[HeaderReplyTo] != "" && headers[HeaderCorrelationID] != ""
+}
+
+// NewReplyEvent creates the reply to the request with the payload, which is published into the reply topic of the request,
+// and returns ErrNotRequest if the event is not a request (see IsRequest).
+// The payload is converted as NewOutgoingEvent does.
+func NewReplyEvent(request Event, payload interface{}, opts ...EventDataOption) (Event, error) {
+	if !IsRequest(request) {
+		return nil, ErrNotRequest
+	}
+
+	headers := request.Headers()
+
+	opts = append(opts, WithHeader(HeaderCorrelationID, headers[HeaderCorrelationID]))
+
+	return NewOutgoingEvent(&EventID{Topic: headers[HeaderReplyTo]}, request.Action(), request.TTL(), payload, opts...)
+}
+
+// NewErrorReplyEvent creates the reply to the request carrying the error instead of a payload,
+// which is returned as *RemoteError by Requester.Request.
+func NewErrorReplyEvent(request Event, err error) (Event, error) {
+	return NewReplyEvent(request, nil, WithHeader(HeaderReplyError, err.Error()))
+}
+
+func newCorrelationID() (string, error) {
+	bytes := make([]byte, 16)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
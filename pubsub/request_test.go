@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// respond replies to the requests of /check received by the server: "allowed" unless the payload is "spam",
+// which is replied with an error. The other requests are never replied.
+func respond(t *testing.T, server UnifiedPubSub) {
+	go func() {
+		for request := range server.Events() {
+			if request.Action() != "/check" {
+				continue
+			}
+
+			var text string
+
+			_ = request.UnmarshalPayload(&text)
+
+			var reply Event
+			var err error
+
+			if text == "spam" {
+				reply, err = NewErrorReplyEvent(request, errors.New("spam is not allowed"))
+			} else {
+				reply, err = NewReplyEvent(request, map[string]interface{}{"allowed": true})
+			}
+
+			if err == nil {
+				err = server.Publish(context.Background(), reply)
+			}
+
+			if err != nil {
+				t.Errorf("Error replying: %v", err)
+			}
+		}
+	}()
+}
+
+func testRequestReply(t *testing.T, server, client UnifiedPubSub) {
+	if err := server.Subscribe(NewTopic("moderation", "")); err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+
+	respond(t, server)
+
+	requester, err := NewRequester(client, "moderation:replies", WithRequestTimeout(200*time.Millisecond))
+
+	if err != nil {
+		t.Fatalf("Error creating requester: %v", err)
+	}
+
+	defer func() {
+		_ = requester.Stop()
+		_, _ = server.Stop()
+	}()
+
+	request := func(action, text string) (Event, error) {
+		event, err := NewTypedOutgoingEvent(&EventID{Topic: "moderation"}, action, 0, text)
+
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+
+		return requester.Request(context.Background(), event)
+	}
+
+	reply, err := request("/check", "hello")
+
+	if err != nil {
+		t.Fatalf("Error requesting: %v", err)
+	}
+
+	var result map[string]interface{}
+
+	if err := reply.UnmarshalPayload(&result); err != nil || result["allowed"] != true {
+		t.Errorf("Expected the reply to be allowed, got %v (%v)", result, err)
+	}
+
+	_, err = request("/check", "spam")
+
+	var remoteErr *RemoteError
+
+	if !errors.As(err, &remoteErr) || remoteErr.Message != "spam is not allowed" {
+		t.Errorf("Expected RemoteError, got %v", err)
+	}
+
+	if _, err := request("/ignored", "hello"); !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("Expected ErrRequestTimeout, got %v", err)
+	}
+}
+
+func TestRequester_Memory(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	testRequestReply(t, WithMemory(WithMemoryBroker(broker)), WithMemory(WithMemoryBroker(broker)))
+}
+
+func TestRequester_MemoryStream(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	// Publish does not create the streams
+	for _, stream := range []string{"moderation", "moderation:replies"} {
+		broker.CreateStream(stream)
+	}
+
+	testRequestReply(t, WithMemoryStream(0, WithMemoryBroker(broker)), WithMemoryStream(0, WithMemoryBroker(broker)))
+}
+
+func TestRequester_RedisPubSub(t *testing.T) {
+	client := newMiniRedis(t)
+
+	testRequestReply(t, New(client), New(client))
+}
+
+func TestRequester_RedisStream(t *testing.T) {
+	client := newMiniRedis(t)
+
+	// Publish does not create the streams
+	for _, stream := range []string{"moderation", "moderation:replies"} {
+		addStreamEntry(t, client, stream, "/created")
+	}
+
+	testRequestReply(t, WithStream(client, 0), WithStream(client, 0))
+}
+
+func TestRequester_Stop(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	server := WithMemory(WithMemoryBroker(broker))
+
+	// the server receives the request, but never replies
+	_ = server.Subscribe(NewTopic("moderation", ""))
+
+	requester, err := NewRequester(WithMemory(WithMemoryBroker(broker)), "moderation:replies")
+
+	if err != nil {
+		t.Fatalf("Error creating requester: %v", err)
+	}
+
+	go func() {
+		<-server.Events()
+		_ = requester.Stop()
+	}()
+
+	event, _ := NewOutgoingEvent(&EventID{Topic: "moderation"}, "/check", 0, "hello")
+
+	if _, err := requester.Request(context.Background(), event); !errors.Is(err, ErrPubSubStopped) {
+		t.Errorf("Expected ErrPubSubStopped, got %v", err)
+	}
+
+	if _, err := requester.Request(context.Background(), event); !errors.Is(err, ErrPubSubStopped) {
+		t.Errorf("Expected ErrPubSubStopped, got %v", err)
+	}
+
+	_, _ = server.Stop()
+}
+
+func TestNewReplyEvent_NotRequest(t *testing.T) {
+	event, _ := NewOutgoingEvent(&EventID{Topic: "moderation"}, "/check", 0, "hello")
+
+	if _, err := NewReplyEvent(event, "allowed"); !errors.Is(err, ErrNotRequest) {
+		t.Errorf("Expected ErrNotRequest, got %v", err)
+	}
+}
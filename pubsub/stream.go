@@ -3,7 +3,6 @@ package pubsub
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/redis/go-redis/v9"
 	"sync"
 	"time"
@@ -17,33 +16,78 @@ var (
 // As the redis stream always returns the last delivered message,
 // the lastSync is used to filter out the messages that are delivered before the lastSync.
 // The internal worker just drops received events created before the lastSync.
-func WithStream(c *redis.Client, lastSync int64) UnifiedPubSub {
+// With WithConsumerGroup, the worker reads as a consumer of the group, and the delivered events must be acknowledged.
+// The client may be a Redis Cluster client, with which the keys of different hash slots are read separately.
+// The stream keys matching a PatternTopic are discovered when subscribing, and then periodically (see WithPatternDiscovery).
+func WithStream(c redis.UniversalClient, lastSync int64, opts ...Option) UnifiedPubSub {
+
+	errChan := newErrorChannel()
 
 	return &pubSubStreamImpl{
-		client:    c,
-		eventChan: make(chan Event),
-		topics:    make(map[string]Topic),
-		mu:        &sync.Mutex{},
-		lastSync:  lastSync,
+		client:       c,
+		eventChan:    make(chan Event),
+		errChan:      errChan,
+		topics:       make(map[string]Topic),
+		unsubscribed: make(map[string]Topic),
+		patterns:     make(map[string]Topic),
+		excluded:     make(map[string]bool),
+		discovery:    newLifecycle(),
+		mu:           &sync.Mutex{},
+		lastSync:     lastSync,
+		opts:         withErrorChannel(opts, errChan),
+		options:      newOptions(opts...),
 	}
 }
 
 type pubSubStreamImpl struct {
-	client *redis.Client
+	client redis.UniversalClient
 
 	eventChan chan Event
 
+	errChan *errorChannel
+
 	topics map[string]Topic
 
+	// unsubscribed keeps the unsubscribed topics, so that their last offsets are still included in the SyncPoint
+	unsubscribed map[string]Topic
+
+	// patterns keeps the subscribed PatternTopic, whose discovered stream keys are kept in topics
+	patterns map[string]Topic
+
+	// excluded keeps the stream keys unsubscribed by their names, which the discovery skips until they are subscribed again
+	excluded map[string]bool
+
+	// discovery runs the periodical discovery of the stream keys matching the patterns
+	discovery *lifecycle
+
+	discovering bool
+
 	mu *sync.Mutex
 
 	worker Worker
 
 	lastSync int64
+
+	opts []Option
+
+	options *options
+
+	stopped bool
 }
 
 // Publish publishes a message to a topic
-func (ps *pubSubStreamImpl) Publish(context context.Context, event Event) error {
+func (ps *pubSubStreamImpl) Publish(context context.Context, event Event) (err error) {
+
+	// the original event is recorded, as the traced one is only built for the wire
+	defer func(event Event) {
+		ps.options.recordPublish(event, err)
+	}(event)
+
+	event, err = injectTraceContext(context, event)
+
+	if err != nil {
+		return err
+	}
 
 	id := event.ID()
 
@@ -53,7 +97,7 @@ func (ps *pubSubStreamImpl) Publish(context context.Context, event Event) error
 
 	jsonData := make(map[string]interface{})
 
-	err := event.NormalizeInto(&jsonData)
+	err = event.NormalizeInto(&jsonData)
 
 	if err != nil {
 		return err
@@ -78,35 +122,135 @@ func (ps *pubSubStreamImpl) Publish(context context.Context, event Event) error
 	return nil
 }
 
+// Subscribe subscribes to the given stream keys, and the keys matching the given PatternTopic.
+// The SyncPoint only carries the offsets of the keys, so the patterns should be subscribed again when resuming from it.
 func (ps *pubSubStreamImpl) Subscribe(topics ...Topic) error {
 
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	updatedTopics := ps.updateTopics(topics)
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	streams := make([]Topic, 0)
+	patterns := make([]Topic, 0)
+
+	for _, topic := range topics {
+		if !isPatternTopic(topic) {
+			streams = append(streams, topic)
+			delete(ps.excluded, topic.Name())
+		} else if _, ok := ps.patterns[topic.Name()]; !ok {
+			patterns = append(patterns, topic)
+		}
+	}
+
+	if len(patterns) > 0 {
+		discovered, err := ps.discoverTopics(ps.discovery.ctx, patterns)
+
+		if err != nil {
+			return err
+		}
+
+		for _, pattern := range patterns {
+			ps.patterns[pattern.Name()] = pattern
+		}
+
+		streams = append(streams, discovered...)
+
+		if !ps.discovering {
+			ps.discovering = true
+			ps.discovery.goroutine(ps.runDiscovery)
+		}
+	}
+
+	updatedTopics := ps.updateTopics(streams)
 
 	if updatedTopics == nil {
 		return nil
 	}
 
+	// the consumer group keeps the last delivered entry by itself,
+	// so there is no need to drop the entries added before re-creating the worker.
+	if ps.worker != nil && ps.options.group == "" {
+		ps.lastSync = max(ps.lastSync, time.Now().UnixMilli())
+	}
+
+	return ps.restartWorker(updatedTopics)
+}
+
+// restartWorker replaces the worker with a new one reading the given topics.
+func (ps *pubSubStreamImpl) restartWorker(topics []Topic) error {
 	if ps.worker != nil {
-		oldWorker := ps.worker
-		oldWorker.Stop()
+		ps.worker.Stop()
 		ps.worker = nil
-		ps.lastSync = max(ps.lastSync, time.Now().UnixMilli())
 	}
 
-	ps.worker = NewStreamWorker(ps.client, ps.lastSync)
+	if len(topics) == 0 {
+		return nil
+	}
+
+	ps.worker = NewStreamWorker(ps.client, ps.lastSync, ps.opts...)
+
+	return ps.worker.Run(topics, ps.eventChan)
+}
+
+// Unsubscribe removes the given stream keys from the worker, or the patterns along with the keys discovered by them.
+// A key unsubscribed by its name is not discovered by the patterns any more, until it is subscribed again.
+// As the worker reads all keys in one XREAD, it would be replaced by a new worker reading the remaining keys from their offsets.
+func (ps *pubSubStreamImpl) Unsubscribe(topics ...string) error {
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stopped {
+		return ErrPubSubStopped
+	}
+
+	removed := false
+
+	for _, name := range topics {
+		if _, ok := ps.patterns[name]; ok {
+			delete(ps.patterns, name)
+
+			for key, topic := range ps.topics {
+				if matched, ok := topic.(*matchedTopic); ok && matched.pattern == name {
+					delete(ps.topics, key)
+					ps.unsubscribed[key] = topic
+					removed = true
+				}
+			}
+		}
+
+		if topic, ok := ps.topics[name]; ok {
+			delete(ps.topics, name)
+			ps.unsubscribed[name] = topic
+			ps.excluded[name] = true
+			removed = true
+		}
+	}
+
+	if !removed {
+		return nil
+	}
+
+	remainingTopics := make([]Topic, 0)
+
+	for _, topic := range ps.topics {
+		remainingTopics = append(remainingTopics, topic)
+	}
 
-	return ps.worker.Run(updatedTopics, ps.eventChan)
+	return ps.restartWorker(remainingTopics)
 }
 
 func (ps *pubSubStreamImpl) Events() <-chan Event {
 	return ps.eventChan
 }
 
+// Errors returns a channel that receives the errors reported by the worker, e.g. DisconnectedError.
+// The channel is buffered, errors are dropped when nobody reads it, and it is closed when Stop() is called.
 func (ps *pubSubStreamImpl) Errors() <-chan error {
-	return nil
+	return ps.errChan.ch
 }
 
 func (ps *pubSubStreamImpl) Topics() []string {
@@ -119,36 +263,72 @@ func (ps *pubSubStreamImpl) Topics() []string {
 		topics = append(topics, topic.Name())
 	}
 
+	for _, pattern := range ps.patterns {
+		topics = append(topics, pattern.Name())
+	}
+
 	return topics
 }
 
 func (ps *pubSubStreamImpl) Stop() (SyncPoint, error) {
+	point, _ := ps.Shutdown(cancelledContext())
+
+	return point, nil
+}
+
+// Shutdown stops reading the streams, and waits for the worker to deliver the in-flight entries until ctx is done.
+// The offsets are captured after the worker exits, so the SyncPoint points to the last entry handed to the receiver.
+func (ps *pubSubStreamImpl) Shutdown(ctx context.Context) (SyncPoint, error) {
 	ps.mu.Lock()
-	defer ps.mu.Unlock()
 
-	if ps.worker == nil {
+	if ps.stopped {
+		ps.mu.Unlock()
 		return SyncPoint{}, nil
 	}
 
+	ps.stopped = true
+
+	ps.mu.Unlock()
+
+	// the discovery takes the lock to restart the worker, so it must be stopped without holding the lock
+	ps.discovery.stop()
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
 	defer func() {
 		ps.worker = nil
+		ps.unsubscribed = make(map[string]Topic)
 		close(ps.eventChan)
+		ps.errChan.close()
 	}()
 
-	fmt.Printf("Stopping StreamPubSub: %v\n", ps.worker)
+	var shutdownErr error
+
+	if ps.worker != nil {
+		ps.options.logger.Debug("Shutting down the stream worker")
+
+		shutdownErr = ps.worker.Shutdown(ctx)
+	}
 
-	ps.worker.Stop()
+	if len(ps.topics) == 0 && len(ps.unsubscribed) == 0 {
+		return SyncPoint{}, shutdownErr
+	}
 
 	point := &SyncPoint{
 		Timestamp: max(ps.lastSync, time.Now().UnixMilli()),
 		Offsets:   make(map[string]string),
 	}
 
+	for _, topic := range ps.unsubscribed {
+		point.Offsets[topic.Name()] = topic.Offset()
+	}
+
 	for _, topic := range ps.topics {
 		point.Offsets[topic.Name()] = topic.Offset()
 	}
 
-	return *point, nil
+	return *point, shutdownErr
 }
 
 func (ps *pubSubStreamImpl) updateTopics(topics []Topic) []Topic {
@@ -159,6 +339,7 @@ func (ps *pubSubStreamImpl) updateTopics(topics []Topic) []Topic {
 
 		if _, ok := ps.topics[topic.Name()]; !ok {
 			ps.topics[topic.Name()] = topic
+			delete(ps.unsubscribed, topic.Name())
 			topicAdded = true
 		}
 	}
@@ -12,6 +12,10 @@ type SyncPoint struct {
 	// Offsets is the last read message IDs for each stream.
 	// Only used for streams.
 	Offsets map[string]string `json:"offsets"`
+
+	// Committed is the last committed offset of each partition, keyed by topic and partition.
+	// Only used for Kafka, as Kafka resumes from the consumer group kept in Offsets.
+	Committed map[string]map[int32]int64 `json:"committed,omitempty"`
 }
 
 func (s *SyncPoint) AsTopics() []Topic {
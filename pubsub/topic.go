@@ -50,3 +50,39 @@ func NewTopic(name, offset string) Topic {
 		offset: offset,
 	}
 }
+
+// PatternTopic represents a glob-style pattern of topics, e.g. "room:*", subscribed as a whole.
+// For Redis PubSub, it is subscribed via PSUBSCRIBE.
+// For Redis Stream, the stream keys matching the pattern are discovered periodically via SCAN MATCH.
+// The events of the matched topics carry the pattern in EventID.Pattern.
+type PatternTopic interface {
+	Topic
+
+	// Pattern returns the pattern, which is also the name of the topic.
+	Pattern() string
+}
+
+type patternTopicImpl struct {
+	topicImpl
+}
+
+func (t *patternTopicImpl) Pattern() string {
+	return t.name
+}
+
+// NewPatternTopic creates a new pattern topic.
+// For Redis Stream, the offset is the one every discovered stream starts from; if empty, it will be MinimumID.
+func NewPatternTopic(pattern, offset string) PatternTopic {
+	return &patternTopicImpl{
+		topicImpl: topicImpl{
+			name:   pattern,
+			offset: offset,
+		},
+	}
+}
+
+// isPatternTopic reports whether the topic is a PatternTopic.
+func isPatternTopic(topic Topic) bool {
+	_, ok := topic.(PatternTopic)
+	return ok
+}
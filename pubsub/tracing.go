@@ -0,0 +1,114 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// injectTraceContext injects the trace context of ctx into the headers of the event, e.g. W3C traceparent and tracestate,
+// via the global propagator of OpenTelemetry (see otel.SetTextMapPropagator).
+// The event is returned as is if there is nothing to inject, otherwise it is wrapped rather than rebuilt,
+// so that a custom Event (e.g. one overriding NormalizeInto) keeps its behavior.
+func injectTraceContext(ctx context.Context, event Event) (Event, error) {
+	carrier := propagation.MapCarrier{}
+
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	if len(carrier) == 0 {
+		return event, nil
+	}
+
+	return &tracedEvent{
+		Event:   event,
+		headers: carrier,
+	}, nil
+}
+
+// tracedEvent adds the trace headers to the event of the caller,
+// which override the existing ones of the same keys.
+type tracedEvent struct {
+	Event
+
+	headers map[string]string
+}
+
+func (e *tracedEvent) Headers() map[string]string {
+	headers := make(map[string]string)
+
+	for key, value := range e.Event.Headers() {
+		headers[key] = value
+	}
+
+	for key, value := range e.headers {
+		headers[key] = value
+	}
+
+	return headers
+}
+
+// NormalizeInto normalizes the wrapped event, and then adds the trace headers the same way as EventData does:
+// flattened with EventHeaderPrefix into a map, or nested under EventHeadersKey into a JSON string or bytes.
+func (e *tracedEvent) NormalizeInto(target interface{}) error {
+	if err := e.Event.NormalizeInto(target); err != nil {
+		return err
+	}
+
+	switch target := target.(type) {
+	case *map[string]interface{}:
+		if *target == nil {
+			*target = make(map[string]interface{})
+		}
+
+		for key, value := range e.headers {
+			(*target)[EventHeaderPrefix+key] = value
+		}
+	case *string:
+		*target = string(e.nestHeaders([]byte(*target)))
+	case *[]byte:
+		*target = e.nestHeaders(*target)
+	}
+
+	return nil
+}
+
+// nestHeaders adds the trace headers under EventHeadersKey of the JSON object.
+// The data is returned as is if it is not a JSON object, as there is nowhere to carry the headers.
+func (e *tracedEvent) nestHeaders(data []byte) []byte {
+	jsonData := make(map[string]interface{})
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep the numbers, e.g. the timestamp, as they are
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&jsonData); err != nil {
+		return data
+	}
+
+	headers, _ := jsonData[EventHeadersKey].(map[string]interface{})
+
+	if headers == nil {
+		headers = make(map[string]interface{})
+	}
+
+	for key, value := range e.headers {
+		headers[key] = value
+	}
+
+	jsonData[EventHeadersKey] = headers
+
+	nested, err := json.Marshal(jsonData)
+
+	if err != nil {
+		return data
+	}
+
+	return nested
+}
+
+// extractTraceContext returns the context carrying the trace context extracted from the headers of the event data.
+func extractTraceContext(data EventData) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(data.Headers()))
+}
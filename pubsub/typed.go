@@ -0,0 +1,44 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// NewTypedEventData creates a new event data with the given action, TTL, and payload of type T.
+// Unlike NewEventData, the payload is always encoded as JSON, even if it is a string or []byte,
+// so that it can always be decoded back as T via DecodePayload.
+func NewTypedEventData[T any](action string, ttl int, payload T, opts ...EventDataOption) (EventData, error) {
+	bytes, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEventData(action, ttl, bytes, opts...)
+}
+
+// NewTypedOutgoingEvent creates a new outgoing event with the given ID, action, TTL, and payload of type T,
+// whose payload is encoded as NewTypedEventData does.
+func NewTypedOutgoingEvent[T any](id *EventID, action string, ttl int, payload T, opts ...EventDataOption) (Event, error) {
+	data, err := NewTypedEventData(action, ttl, payload, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventImpl{
+		id:        id,
+		ctx:       context.Background(),
+		EventData: data,
+	}, nil
+}
+
+// DecodePayload decodes the payload of the event data as T, via EventData.UnmarshalPayload.
+func DecodePayload[T any](data EventData) (T, error) {
+	var payload T
+
+	err := data.UnmarshalPayload(&payload)
+
+	return payload, err
+}
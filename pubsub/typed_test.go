@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+type typedPayload struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func roundTrip[T any](t *testing.T, payload T) T {
+	event, err := NewTypedOutgoingEvent(&EventID{Topic: "typed"}, "/typed", 1, payload)
+
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+
+	var decoded T
+
+	// through Redis PubSub (string) and Redis Stream (map)
+	var str string
+	var values map[string]interface{}
+
+	if err := event.NormalizeInto(&str); err != nil {
+		t.Fatalf("failed to normalize event: %v", err)
+	}
+
+	if err := event.NormalizeInto(&values); err != nil {
+		t.Fatalf("failed to normalize event: %v", err)
+	}
+
+	for _, data := range []interface{}{str, values} {
+		incoming, err := NewIncomingEvent(&EventID{Topic: "typed"}, data)
+
+		if err != nil {
+			t.Fatalf("failed to parse event: %v", err)
+		}
+
+		decoded, err = DecodePayload[T](incoming)
+
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+	}
+
+	return decoded
+}
+
+func TestTypedEvent_RoundTrip(t *testing.T) {
+	if decoded := roundTrip(t, typedPayload{Name: "typed", Count: 2}); decoded.Name != "typed" || decoded.Count != 2 {
+		t.Errorf("expected the struct payload to round trip, got %v", decoded)
+	}
+
+	// a string would be sent as is by NewOutgoingEvent, which is not valid JSON
+	if decoded := roundTrip(t, "plain text"); decoded != "plain text" {
+		t.Errorf("expected the string payload to round trip, got %s", decoded)
+	}
+
+	if decoded := roundTrip(t, []int{1, 2, 3}); len(decoded) != 3 || decoded[2] != 3 {
+		t.Errorf("expected the slice payload to round trip, got %v", decoded)
+	}
+}
+
+func TestDecodePayload_Mismatch(t *testing.T) {
+	data, err := NewTypedEventData("/typed", 1, "not a struct")
+
+	if err != nil {
+		t.Fatalf("failed to create event data: %v", err)
+	}
+
+	if _, err := DecodePayload[typedPayload](data); err == nil {
+		t.Errorf("expected the string payload not to be decoded as struct")
+	}
+}
@@ -3,43 +3,76 @@ package pubsub
 import (
 	"context"
 	"errors"
-	"fmt"
-	"github.com/edgejumps/sportstalk-common-utils/logger"
 	"github.com/redis/go-redis/v9"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
 	ErrWorkerAlreadyStarted = errors.New("worker already started")
 )
 
+const (
+	reclaimBatchSize   = 100
+	pubSubPingInterval = 30 * time.Second
+)
+
 // Worker is an interface that defines the behavior of a worker that consumes messages from a topic.
 // For Redis Stream worker, it will normalize the topic's offset to the latest message ID if not set.
 // For Redis PubSub worker, it will subscribe to the topic directly without caring the offset.
 // For Kafka worker, it will subscribe to the topic with the offset (groupID) provided.
+//
+// A worker marks an event as consumed (e.g. syncs the topic's offset) only after it is handed to the receiver,
+// so the offsets of its topics always match the delivered events once it is stopped.
 type Worker interface {
 	Run(topics []Topic, receiver chan<- Event) error
+
+	// Stop stops the worker, abandoning the in-flight events, and waits for its goroutines to exit.
 	Stop()
+
+	// Shutdown stops fetching, lets the in-flight events be delivered until ctx is done,
+	// then abandons the rest and waits for the goroutines to exit.
+	// It returns ctx.Err() if the in-flight events are abandoned.
+	Shutdown(ctx context.Context) error
 }
 
 type workerImpl struct {
-	client *redis.Client
+	client redis.UniversalClient
 
-	ctx    context.Context
-	cancel context.CancelFunc
+	*lifecycle
 
 	rpb *redis.PubSub
+
+	channels map[string]bool
+
+	// patterns are the subscribed patterns of PatternTopic
+	patterns map[string]bool
+
+	mu *sync.Mutex
+
+	options *options
 }
 
-func NewWorker(c *redis.Client) Worker {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewWorker(c redis.UniversalClient, opts ...Option) Worker {
+	return newWorker(c, opts...)
+}
 
+func newWorker(c redis.UniversalClient, opts ...Option) *workerImpl {
 	return &workerImpl{
-		client: c,
-		ctx:    ctx,
-		cancel: cancel,
+		client:    c,
+		lifecycle: newLifecycle(),
+		channels:  make(map[string]bool),
+		patterns:  make(map[string]bool),
+		mu:        &sync.Mutex{},
+		options:   newOptions(opts...),
 	}
 }
 
+// Run subscribes the channels, and the patterns of PatternTopic via PSUBSCRIBE.
+// Sharded channels have no pattern subscription, so PatternTopic is rejected with WithShardedPubSub.
 func (w *workerImpl) Run(topics []Topic, receiver chan<- Event) error {
 
 	if w.rpb != nil {
@@ -47,77 +80,288 @@ func (w *workerImpl) Run(topics []Topic, receiver chan<- Event) error {
 	}
 
 	channels := make([]string, 0)
+	patterns := make([]string, 0)
 
 	for _, topic := range topics {
-		channels = append(channels, topic.Name())
+		if isPatternTopic(topic) {
+			patterns = append(patterns, topic.Name())
+		} else {
+			channels = append(channels, topic.Name())
+		}
+	}
+
+	if w.options.sharded && len(patterns) > 0 {
+		return ErrPatternTopicNotSupported
 	}
 
-	sub := w.client.Subscribe(w.ctx, channels...)
+	subscribe := w.client.Subscribe
+
+	if w.options.sharded {
+		subscribe = w.client.SSubscribe
+	}
+
+	var sub *redis.PubSub
+
+	if len(channels) > 0 {
+		sub = subscribe(w.ctx, channels...)
+	} else {
+		sub = w.client.PSubscribe(w.ctx, patterns...)
+	}
 
 	_, err := sub.Receive(w.ctx)
 
+	if err == nil && len(channels) > 0 && len(patterns) > 0 {
+		err = sub.PSubscribe(w.ctx, patterns...)
+	}
+
 	if err != nil {
+		_ = sub.Close()
 		return err
 	}
 
 	w.rpb = sub
 
-	ch := sub.Channel()
+	w.mu.Lock()
+
+	for _, channel := range channels {
+		w.channels[channel] = true
+	}
+
+	for _, pattern := range patterns {
+		w.patterns[pattern] = true
+	}
 
-	go func() {
+	w.mu.Unlock()
 
-		for {
-			select {
-			case <-w.ctx.Done():
-				return
-			case msg := <-ch:
-				event, err := NewIncomingEvent(&EventID{
-					Topic: msg.Channel,
-				}, msg.Payload)
+	w.goroutine(func() {
+		w.receive(sub, receiver)
+	})
+
+	return nil
+}
+
+// receive delivers the messages of the subscription to the receiver until the worker is stopped.
+// redis.PubSub reconnects and resubscribes all channels by itself on the next receive after a failure,
+// so the worker just reports the disconnection and paces the retries.
+func (w *workerImpl) receive(sub *redis.PubSub, receiver chan<- Event) {
+	defer recoverWorker(w.options)
+
+	r := newReconnector(w.options, "SUBSCRIBE", w.subscribedChannels)
+
+	for {
+		msg, err := sub.ReceiveTimeout(w.ctx, pubSubPingInterval)
+
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			var netErr net.Error
 
-				if err != nil {
-					logger.Errorf("Error parsing incoming event payload: %v", err)
+			// ping the idle connection, so that a broken one would fail the next receive
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = sub.Ping(w.ctx)
+
+				if err == nil {
 					continue
 				}
+			}
 
-				receiver <- event
+			if !r.failed(w.ctx, err) {
+				return
 			}
+
+			continue
 		}
 
-	}()
+		r.succeeded()
 
-	return nil
+		m, ok := msg.(*redis.Message)
+
+		// the messages published before the server processes UNSUBSCRIBE may still arrive
+		if !ok || !w.subscribed(m) {
+			continue
+		}
+
+		event, err := NewIncomingEvent(&EventID{
+			Topic:   m.Channel,
+			Pattern: m.Pattern,
+		}, m.Payload)
+
+		if err != nil {
+			w.options.logger.Error("Error parsing incoming event payload",
+				LogKeyTopic, m.Channel, LogKeyError, err)
+			w.options.reportError(&ParseError{Topic: m.Channel, Err: err})
+			w.options.recordParseFailure(m.Channel)
+			w.deadLetter(m, err)
+			continue
+		}
+
+		if !w.options.deliverMeasured(w.lifecycle, receiver, event) || w.stopping() {
+			return
+		}
+	}
+}
+
+// subscribedChannels returns the channels and patterns currently subscribed by the worker.
+func (w *workerImpl) subscribedChannels() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	channels := make([]string, 0)
+
+	for channel := range w.channels {
+		channels = append(channels, channel)
+	}
+
+	for pattern := range w.patterns {
+		channels = append(channels, pattern)
+	}
+
+	return channels
+}
+
+// subscribed reports whether the channel (or the pattern) of the message is still subscribed by the worker.
+func (w *workerImpl) subscribed(msg *redis.Message) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if msg.Pattern != "" {
+		return w.patterns[msg.Pattern]
+	}
+
+	return w.channels[msg.Channel]
+}
+
+// deadLetter writes the unparseable message into the dead-letter stream of its channel if enabled.
+func (w *workerImpl) deadLetter(msg *redis.Message, cause error) {
+	if w.options.deadLetterSuffix == "" {
+		return
+	}
+
+	dlq := DeadLetterTopic(msg.Channel, w.options.deadLetterSuffix)
+
+	err := writeDeadLetter(w.ctx, w.client, dlq, &DeadLetter{
+		Topic: msg.Channel,
+		Error: cause.Error(),
+		Raw:   msg.Payload,
+	})
+
+	if err != nil {
+		w.options.logger.Error("Error writing dead letter",
+			LogKeyTopic, msg.Channel, LogKeyError, err)
+		w.options.reportError(&TransportError{Op: "XADD", Topics: []string{dlq}, Err: err})
+	}
+}
+
+// unsubscribe unsubscribes the given channels (or patterns) owned by the worker,
+// and returns the number of channels and patterns the worker is still subscribing.
+func (w *workerImpl) unsubscribe(channels ...string) (int, error) {
+
+	if w.rpb == nil {
+		return 0, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	owned := make([]string, 0)
+	ownedPatterns := make([]string, 0)
+
+	for _, channel := range channels {
+		if w.channels[channel] {
+			owned = append(owned, channel)
+		} else if w.patterns[channel] {
+			ownedPatterns = append(ownedPatterns, channel)
+		}
+	}
+
+	unsubscribe := w.rpb.Unsubscribe
+
+	if w.options.sharded {
+		unsubscribe = w.rpb.SUnsubscribe
+	}
+
+	if len(owned) > 0 {
+		err := unsubscribe(w.ctx, owned...)
+
+		if err != nil {
+			return len(w.channels) + len(w.patterns), err
+		}
+
+		for _, channel := range owned {
+			delete(w.channels, channel)
+		}
+	}
+
+	if len(ownedPatterns) > 0 {
+		err := w.rpb.PUnsubscribe(w.ctx, ownedPatterns...)
+
+		if err != nil {
+			return len(w.channels) + len(w.patterns), err
+		}
+
+		for _, pattern := range ownedPatterns {
+			delete(w.patterns, pattern)
+		}
+	}
+
+	return len(w.channels) + len(w.patterns), nil
 }
 
 func (w *workerImpl) Stop() {
+	w.cancel()
+	w.closeSubscription()
+	w.stop()
+}
+
+// Shutdown stops receiving messages, and waits for the in-flight event to be delivered until ctx is done.
+// Redis PubSub cannot re-deliver an abandoned event, so it is lost.
+func (w *workerImpl) Shutdown(ctx context.Context) error {
+	w.cancel()
+	w.closeSubscription()
+
+	return w.shutdown(ctx)
+}
+
+// closeSubscription closes the subscription, which also interrupts the blocking receive.
+func (w *workerImpl) closeSubscription() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	if w.rpb != nil {
 		_ = w.rpb.Close()
 		w.rpb = nil
 	}
-
-	w.cancel()
 }
 
 type streamWorkerImpl struct {
-	client *redis.Client
+	client redis.UniversalClient
 
-	ctx      context.Context
-	canceler context.CancelFunc
+	*lifecycle
 
 	topics map[string]Topic
 
+	// offsets mirrors the offsets of the topics for sampleLag, as a Topic is not safe for concurrent use
+	offsets   map[string]string
+	offsetsMu *sync.Mutex
+
 	lastSync int64
-}
 
-func NewStreamWorker(c *redis.Client, lastSync int64) Worker {
-	ctx, canceler := context.WithCancel(context.Background())
+	options *options
+}
 
+// NewStreamWorker creates a worker reading the streams from the offsets of the topics.
+// With WithConsumerGroup, it reads the entries never delivered to the group instead.
+func NewStreamWorker(c redis.UniversalClient, lastSync int64, opts ...Option) Worker {
 	return &streamWorkerImpl{
-		client:   c,
-		ctx:      ctx,
-		canceler: canceler,
-		topics:   make(map[string]Topic),
-		lastSync: lastSync,
+		client:    c,
+		lifecycle: newLifecycle(),
+		topics:    make(map[string]Topic),
+		offsets:   make(map[string]string),
+		offsetsMu: &sync.Mutex{},
+		lastSync:  lastSync,
+		options:   newOptions(opts...),
 	}
 }
 
@@ -127,85 +371,448 @@ func (w *streamWorkerImpl) Run(topics []Topic, receiver chan<- Event) error {
 		return ErrWorkerAlreadyStarted
 	}
 
+	// the keys of a pattern are discovered by WithStream, as XREAD only reads the given keys
+	for _, topic := range topics {
+		if isPatternTopic(topic) {
+			return ErrPatternTopicNotSupported
+		}
+	}
+
 	for _, topic := range topics {
 		w.topics[topic.Name()] = topic
+		w.offsets[topic.Name()] = topic.Offset()
 	}
 
-	go func() {
-		defer func() {
+	if w.options.group != "" {
+		err := w.createGroups()
 
-			if r := recover(); r != nil {
-				fmt.Printf("panic recovered\n")
-			}
-		}()
+		if err != nil {
+			w.topics = make(map[string]Topic)
+			return err
+		}
+	}
 
-		for {
+	// a Redis Cluster cannot XREAD the keys of different slots at once, so every slot is read by its own loop
+	for _, keys := range slotGroups(w.client, w.topicNames()) {
+		keys := keys
 
-			keys := make([]string, 0)
-			ids := make([]string, 0)
+		w.goroutine(func() {
+			w.readStreams(keys, receiver)
+		})
+	}
 
-			for _, topic := range w.topics {
-				keys = append(keys, topic.Name())
+	if w.options.group != "" && w.options.reclaimInterval > 0 {
+		w.goroutine(func() {
+			w.reclaim(receiver)
+		})
+	}
 
-				offset := topic.Offset()
+	if w.options.metrics != nil {
+		w.goroutine(w.sampleLag)
+	}
 
-				if offset == "" {
-					offset = string(MinimumID)
-				}
+	return nil
+}
+
+// readStreams reads the given stream keys and delivers their entries to the receiver until the worker is stopped.
+func (w *streamWorkerImpl) readStreams(keys []string, receiver chan<- Event) {
+	defer recoverWorker(w.options)
+
+	r := newReconnector(w.options, "XREAD", func() []string {
+		return keys
+	})
 
-				ids = append(ids, offset)
+	for {
+
+		select {
+		case <-w.ctx.Done():
+			w.options.logger.Debug("Stream worker done", LogKeyTopic, keys)
+			return
+		default:
+			streamMessages, err := w.read(keys)
+
+			// the blocking read timed out without new entries
+			if errors.Is(err, redis.Nil) {
+				r.succeeded()
+				continue
 			}
 
-			select {
-			case <-w.ctx.Done():
-				fmt.Printf("Worker done: %v\n", w)
-				return
-			default:
-				streamMessages, err := w.client.XRead(w.ctx, &redis.XReadArgs{
-					Streams: append(keys, ids...),
-				}).Result()
+			if err != nil {
+				if w.ctx.Err() != nil {
+					return
+				}
+
+				w.options.logger.Warn("Error reading streams", LogKeyTopic, keys, LogKeyError, err)
+
+				// the groups are gone if the server restarted without persistence,
+				// so they should be re-created from the offsets of the topics.
+				if w.options.group != "" && strings.HasPrefix(err.Error(), "NOGROUP") {
+					err = w.createGroups()
 
-				if err != nil {
-					fmt.Printf("Error reading stream: %v\n", err)
+					if err == nil {
+						continue
+					}
+				}
+
+				// the client reconnects by itself on the next read,
+				// which resumes every stream from the offset of its topic.
+				if !r.failed(w.ctx, err) {
 					return
 				}
 
-				for _, stream := range streamMessages {
-					for _, msg := range stream.Messages {
-						event, err := NewIncomingEvent(&EventID{
-							Topic:   stream.Stream,
-							EntryID: msg.ID,
-						}, msg.Values)
+				continue
+			}
+
+			r.succeeded()
 
-						if err != nil {
-							logger.Errorf("Error parsing incoming event payload: %v", err)
-							continue
-						}
+			// the worker may be stopped while blocking on XREAD,
+			// e.g. replaced by a new worker after unsubscribing some keys.
+			if w.ctx.Err() != nil {
+				return
+			}
 
-						fmt.Printf("Event timestamp: %d, lastSync: %d\n", event.Timestamp(), w.lastSync)
+			for _, stream := range streamMessages {
+				topic := w.topics[stream.Stream]
 
-						if event.Timestamp() <= w.lastSync {
-							continue
-						}
+				for _, msg := range stream.Messages {
+					if !w.consume(stream.Stream, msg, receiver) {
+						return
+					}
 
-						receiver <- event
+					// the offset only moves past the entries handed to the receiver (or dropped),
+					// so that the abandoned ones would be read again from the SyncPoint.
+					if topic != nil {
+						topic.SyncOffset(msg.ID)
+						w.syncOffset(stream.Stream, msg.ID)
 					}
 
-					if topic, ok := w.topics[stream.Stream]; ok {
-						topic.SyncOffset(stream.Messages[len(stream.Messages)-1].ID)
+					if w.stopping() {
+						return
 					}
 				}
 			}
 		}
+	}
+}
+
+// syncOffset mirrors the offset synced into the topic of the stream, see offset.
+func (w *streamWorkerImpl) syncOffset(stream, offset string) {
+	w.offsetsMu.Lock()
+	defer w.offsetsMu.Unlock()
+
+	w.offsets[stream] = offset
+}
+
+// offset returns the offset of the topic of the stream, which is safe to read from any goroutine of the worker.
+func (w *streamWorkerImpl) offset(stream string) string {
+	w.offsetsMu.Lock()
+	defer w.offsetsMu.Unlock()
+
+	return w.offsets[stream]
+}
+
+// consume hands the entry to the receiver, and returns false if the delivery is abandoned.
+// The entries that cannot be parsed, or created before the lastSync, are dropped.
+func (w *streamWorkerImpl) consume(stream string, msg redis.XMessage, receiver chan<- Event) bool {
+	event, err := w.newEvent(stream, msg, 1)
+
+	if err != nil {
+		w.options.logger.Error("Error parsing incoming event payload",
+			LogKeyTopic, stream, LogKeyEntryID, msg.ID, LogKeyError, err)
+		w.options.reportError(&ParseError{Topic: stream, EntryID: msg.ID, Err: err})
+		w.options.recordParseFailure(stream)
+		w.deadLetter(stream, msg, err)
+		return true
+	}
+
+	if event.Timestamp() <= w.lastSync {
+		w.options.logger.Debug("Dropping the event created before the last sync",
+			LogKeyTopic, stream, LogKeyEntryID, msg.ID, LogKeyAction, event.Action(),
+			"timestamp", event.Timestamp(), "last_sync", w.lastSync)
+
+		// the skipped entries would never be processed, so they should not stay pending in the group
+		err = Ack(w.ctx, event)
+
+		if err != nil {
+			w.options.reportError(&TransportError{Op: "XACK", Topics: []string{stream}, Err: err})
+		}
+
+		return true
+	}
+
+	return w.options.deliverMeasured(w.lifecycle, receiver, event)
+}
+
+// topicNames returns the stream keys read by the worker.
+func (w *streamWorkerImpl) topicNames() []string {
+	names := make([]string, 0)
+
+	for name := range w.topics {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// newEvent creates an event from the stream entry.
+// In the consumer group mode, the event would be an AckableEvent with the given delivery count.
+func (w *streamWorkerImpl) newEvent(stream string, msg redis.XMessage, deliveries int64) (Event, error) {
+	id := &EventID{
+		Topic:   stream,
+		EntryID: msg.ID,
+	}
+
+	if matched, ok := w.topics[stream].(*matchedTopic); ok {
+		id.Pattern = matched.pattern
+	}
+
+	event, err := NewIncomingEvent(id, msg.Values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if w.options.group == "" {
+		return event, nil
+	}
+
+	return &streamGroupEvent{
+		Event:      event,
+		client:     w.client,
+		group:      w.options.group,
+		deliveries: deliveries,
+	}, nil
+}
+
+// reclaim periodically claims the entries idle for too long in the PEL of the group,
+// and re-delivers them to the receiver until the worker is stopped.
+func (w *streamWorkerImpl) reclaim(receiver chan<- Event) {
+	defer recoverWorker(w.options)
+
+	ticker := time.NewTicker(w.options.reclaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, topic := range w.topics {
+				err := w.reclaimStream(topic.Name(), receiver)
+
+				if err != nil && w.ctx.Err() == nil {
+					w.options.logger.Error("Error reclaiming pending entries",
+						LogKeyTopic, topic.Name(), LogKeyError, err)
+					w.options.reportError(&TransportError{Op: "XAUTOCLAIM", Topics: []string{topic.Name()}, Err: err})
+				}
+			}
+		}
+	}
+}
+
+func (w *streamWorkerImpl) reclaimStream(stream string, receiver chan<- Event) error {
+	start := string(MinimumID)
+
+	for {
+		messages, next, err := w.client.XAutoClaim(w.ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    w.options.group,
+			Consumer: w.options.consumer,
+			MinIdle:  w.options.reclaimIdle,
+			Start:    start,
+			Count:    reclaimBatchSize,
+		}).Result()
+
+		if err != nil {
+			return err
+		}
+
+		deliveries, err := w.deliveryCounts(stream, messages)
+
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range messages {
+			if w.options.maxDeliveries > 0 && deliveries[msg.ID] > w.options.maxDeliveries {
+				w.deadLetter(stream, msg, ErrMaxDeliveriesExceeded)
+				continue
+			}
+
+			event, err := w.newEvent(stream, msg, deliveries[msg.ID])
+
+			if err != nil {
+				w.options.logger.Error("Error parsing incoming event payload",
+					LogKeyTopic, stream, LogKeyEntryID, msg.ID, LogKeyError, err)
+				w.options.reportError(&ParseError{Topic: stream, EntryID: msg.ID, Err: err})
+				w.options.recordParseFailure(stream)
+				w.deadLetter(stream, msg, err)
+				continue
+			}
+
+			if !w.options.deliverMeasured(w.lifecycle, receiver, event) || w.stopping() {
+				return nil
+			}
+		}
+
+		// XAUTOCLAIM returns 0-0 as the next start once the whole PEL is scanned
+		if next == "" || next == "0-0" || next == string(MinimumID) {
+			return nil
+		}
+
+		start = next
+	}
+}
+
+// deadLetter writes the entry into the dead-letter stream of the stream if enabled.
+// In the consumer group mode, the entry would be acknowledged once it is dead-lettered, so that it leaves the PEL.
+func (w *streamWorkerImpl) deadLetter(stream string, msg redis.XMessage, cause error) {
+	if w.options.deadLetterSuffix == "" {
+		return
+	}
+
+	dlq := DeadLetterTopic(stream, w.options.deadLetterSuffix)
+
+	err := writeDeadLetter(w.ctx, w.client, dlq, &DeadLetter{
+		Topic:   stream,
+		EntryID: msg.ID,
+		Error:   cause.Error(),
+		Values:  msg.Values,
+	})
+
+	if err != nil {
+		w.options.logger.Error("Error writing dead letter",
+			LogKeyTopic, stream, LogKeyEntryID, msg.ID, LogKeyError, err)
+		w.options.reportError(&TransportError{Op: "XADD", Topics: []string{dlq}, Err: err})
+		return
+	}
+
+	if w.options.group != "" {
+		err = w.client.XAck(w.ctx, stream, w.options.group, msg.ID).Err()
+
+		if err != nil {
+			w.options.logger.Error("Error acknowledging dead letter",
+				LogKeyTopic, stream, LogKeyEntryID, msg.ID, LogKeyError, err)
+			w.options.reportError(&TransportError{Op: "XACK", Topics: []string{stream}, Err: err})
+		}
+	}
+}
+
+// deliveryCounts returns the delivery counts of the claimed entries from XPENDING.
+// Every entry is looked up by its own ID, as the range of the claimed entries may also hold the other entries
+// pending on this consumer, which XAUTOCLAIM did not claim.
+func (w *streamWorkerImpl) deliveryCounts(stream string, messages []redis.XMessage) (map[string]int64, error) {
+	counts := make(map[string]int64)
+
+	if len(messages) == 0 {
+		return counts, nil
+	}
+
+	cmds := make([]*redis.XPendingExtCmd, 0, len(messages))
+
+	_, err := w.client.Pipelined(w.ctx, func(pipe redis.Pipeliner) error {
+		for _, msg := range messages {
+			cmds = append(cmds, pipe.XPendingExt(w.ctx, &redis.XPendingExtArgs{
+				Stream:   stream,
+				Group:    w.options.group,
+				Start:    msg.ID,
+				End:      msg.ID,
+				Count:    1,
+				Consumer: w.options.consumer,
+			}))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cmd := range cmds {
+		for _, entry := range cmd.Val() {
+			counts[entry.ID] = entry.RetryCount
+		}
+	}
+
+	return counts, nil
+}
+
+// read reads the streams from the offsets of the topics via XREAD,
+// or the never delivered entries via XREADGROUP in the consumer group mode.
+// It blocks for at most options.block, and returns redis.Nil if no entry arrives in time.
+func (w *streamWorkerImpl) read(keys []string) ([]redis.XStream, error) {
+	streams := make([]string, 0, 2*len(keys))
+	streams = append(streams, keys...)
+
+	for _, key := range keys {
+		offset := w.topics[key].Offset()
+
+		if w.options.group != "" {
+			offset = string(LastDeliveredID)
+		} else if offset == "" {
+			offset = string(MinimumID)
+		}
 
-	}()
+		streams = append(streams, offset)
+	}
+
+	if w.options.group != "" {
+		return w.client.XReadGroup(w.ctx, &redis.XReadGroupArgs{
+			Group:    w.options.group,
+			Consumer: w.options.consumer,
+			Streams:  streams,
+			Count:    w.options.count,
+			Block:    w.options.block,
+		}).Result()
+	}
+
+	return w.client.XRead(w.ctx, &redis.XReadArgs{
+		Streams: streams,
+		Count:   w.options.count,
+		Block:   w.options.block,
+	}).Result()
+}
+
+// createGroups creates the consumer group for each stream, starting from the topic's offset.
+// The stream would be created if it does not exist, and an existing group is left as it is.
+func (w *streamWorkerImpl) createGroups() error {
+	for _, topic := range w.topics {
+		start := topic.Offset()
+
+		if start == "" {
+			start = string(MinimumID)
+		}
+
+		err := w.client.XGroupCreateMkStream(w.ctx, topic.Name(), w.options.group, start).Err()
+
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// Stop stops the worker from consuming messages from the topic.
-// we will not wait for the worker to stop completely, as we may re-create a new worker to replace it.
-// However, we would copy and merge its SyncPoint with pubSubStreamImpl's SyncPoint
+// recoverWorker recovers the panic of a worker goroutine, and reports it as PanicError.
+func recoverWorker(o *options) {
+	if r := recover(); r != nil {
+		o.reportError(&PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		})
+	}
+}
+
+// Stop stops the worker from consuming messages from the topic, abandoning the in-flight events.
+// It waits for the worker to exit, which may take as long as a blocking read (see WithStreamRead),
+// so that the offsets of its topics are final once it returns, even if a new worker is created to replace it.
 func (w *streamWorkerImpl) Stop() {
-	w.canceler()
+	w.stop()
+}
+
+// Shutdown stops reading the streams, and waits for the in-flight events to be delivered until ctx is done.
+// The offsets of the topics only cover the delivered events once it returns.
+func (w *streamWorkerImpl) Shutdown(ctx context.Context) error {
+	return w.shutdown(ctx)
 }